@@ -1,4 +1,9 @@
 #!/usr/bin/env bash
 function ev() {
-  eval "$(envirou "$@")"
+  # envirou writes the shell commands to fd 3 and keeps stdout for its own output
+  local envirou_commands envirou_status
+  { envirou_commands="$(ENVIROU_CMD_FD=3 envirou --shell bash "$@" 3>&1 1>&4 4>&-)"; } 4>&1
+  envirou_status=$?
+  eval "$envirou_commands"
+  return $envirou_status
 }
\ No newline at end of file
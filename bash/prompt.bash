@@ -0,0 +1,10 @@
+#!/usr/bin/env bash
+__envirou_prompt() {
+  local segment
+  segment="$(envirou prompt 2>/dev/null)"
+  [ -n "$segment" ] && printf '%s ' "$segment"
+}
+case "$PS1" in
+  *__envirou_prompt*) ;;
+  *) PS1='$(__envirou_prompt)'"$PS1" ;;
+esac
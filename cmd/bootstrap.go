@@ -2,19 +2,31 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/sverrirab/envirou/pkg/output"
 	"github.com/sverrirab/envirou/pkg/shell"
 )
 
 // setCmd represents the set command
 var bootstrapCmd = &cobra.Command{
-	Use:       "bootstrap [bash|zsh|powershell|bat]",
-	Short:     "Bootstrap current shell",
-	Long:      `Run this in your shell initialization script`,
+	Use:   "bootstrap [bash|zsh|fish|nushell|elvish|tcsh|powershell|bat|starship]",
+	Short: "Bootstrap current shell",
+	Long: `Run this in your shell initialization script
+
+With --prompt the active profiles are also shown in the prompt (PS1 for bash,
+RPROMPT for zsh). For starship append the output of "bootstrap starship" to
+~/.config/starship.toml instead.
+
+With --completion tab completion is also set up for envirou and ev (bash, zsh,
+fish and PowerShell).
+
+The nushell, elvish and tcsh scripts are printed as is, see the instructions for
+each shell in the README.`,
 	GroupID:   "configuration",
-	ValidArgs: []string{"bash", "zsh", "powershell", "bat"},
+	ValidArgs: []string{"bash", "zsh", "fish", "nushell", "elvish", "tcsh", "powershell", "bat", "starship"},
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
 			return fmt.Errorf("only provide one argument: type of shell to bootstrap")
@@ -26,26 +38,76 @@ var bootstrapCmd = &cobra.Command{
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
+		completion := ""
+		if addCompletion {
+			var err error
+			if completion, err = completionScript(args[0]); err != nil {
+				output.Printf("%s\n", err.Error())
+				os.Exit(1)
+			}
+		}
 		if args[0] == "powershell" {
-			app.sh = shell.NewShell(true, false)
+			app.sh, _ = shell.Lookup("powershell")
 			app.shellCommands = append(app.shellCommands, collapseToOneLine(powershellBootstrap))
 			if addPrompt {
 				app.shellCommands = append(app.shellCommands, collapseToOneLine(powershellPrompt))
 			}
+			if addCompletion {
+				app.shellCommands = append(app.shellCommands, strings.Split(strings.TrimSpace(completion), "\n")...)
+			}
+		} else if args[0] == "fish" {
+			app.sh, _ = shell.Lookup("fish")
+			script := removeFirstLine(fishBootstrap)
+			if addCompletion {
+				script = joinLines(script, completion)
+			}
+			app.shellCommands = append(app.shellCommands, script)
 		} else if args[0] == "bat" {
+			app.sh, _ = shell.Lookup("bat")
 			app.shellCommands = append(app.shellCommands, batBootstrap)
+		} else if script, found := verbatimScripts()[args[0]]; found {
+			// Not evaluated by the shell, printed as is
+			fmt.Print(script)
 		} else { // bash + zsh
-			// Removing the she-bang line from the script
-			app.shellCommands = append(app.shellCommands, removeFirstLine(bashBootstrap))
+			app.sh, _ = shell.Lookup(args[0])
+			// Removing the she-bang lines from the scripts
+			script := removeFirstLine(bashBootstrap)
+			if args[0] == "zsh" {
+				// Same wrapper, but tell envirou it is zsh
+				script = strings.ReplaceAll(script, "--shell bash", "--shell zsh")
+			}
+			if addPrompt && args[0] == "zsh" {
+				script = joinLines(script, removeFirstLine(zshPrompt))
+			} else if addPrompt {
+				script = joinLines(script, removeFirstLine(bashPrompt))
+			}
+			if addCompletion {
+				script = joinLines(script, completion)
+			}
+			app.shellCommands = append(app.shellCommands, script)
 		}
 	},
 }
 
-var addPrompt bool
+var (
+	addPrompt     bool
+	addCompletion bool
+)
+
+// verbatimScripts are the bootstrap scripts that are saved or sourced rather than evaluated.
+func verbatimScripts() map[string]string {
+	return map[string]string{
+		"nushell":  nushellBootstrap,
+		"elvish":   elvishBootstrap,
+		"tcsh":     tcshBootstrap,
+		"starship": starshipModule,
+	}
+}
 
 func init() {
 	addCommand(bootstrapCmd)
-	bootstrapCmd.Flags().BoolVarP(&addPrompt, "prompt", "p", addPrompt, "Also modify prompt (PowerShell only)")
+	bootstrapCmd.Flags().BoolVarP(&addPrompt, "prompt", "p", addPrompt, "Also show active profiles in the prompt (bash, zsh and PowerShell)")
+	bootstrapCmd.Flags().BoolVarP(&addCompletion, "completion", "c", addCompletion, "Also set up tab completion (bash, zsh, fish and PowerShell)")
 }
 
 func removeFirstLine(s string) string {
@@ -56,13 +118,19 @@ func removeFirstLine(s string) string {
 	return s
 }
 
+// joinLines appends script b to script a on a new line.
+func joinLines(a, b string) string {
+	return strings.TrimRight(a, "\n") + "\n" + b
+}
+
 // collapseToOneLine converts a multi-line script to a single line
 // by replacing newlines with "; " and collapsing extra whitespace.
+// Comment lines are removed since they would comment out the rest of the line.
 func collapseToOneLine(s string) string {
 	var parts []string
 	for _, line := range strings.Split(s, "\n") {
 		trimmed := strings.TrimSpace(line)
-		if trimmed != "" {
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
 			parts = append(parts, trimmed)
 		}
 	}
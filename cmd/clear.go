@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sverrirab/envirou/pkg/data"
+	"github.com/sverrirab/envirou/pkg/output"
+)
+
+var clearForce bool
+
+var clearCmd = &cobra.Command{
+	Use:   "clear GROUP [GROUP] ...",
+	Short: "Unset every variable in one or more groups",
+	Long: `Remove all variables matching the patterns of the given groups from the current environment.
+
+Ignored groups (names starting with "..") are refused unless --force is given.
+Use --dry-run to see what would be removed.`,
+	GroupID:           "groups",
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeGroups,
+	Run: func(cmd *cobra.Command, args []string) {
+		groups := &app.configuration.Groups
+		for _, groupName := range args {
+			if _, found := groups.GetPatterns(groupName); !found {
+				output.Printf("Group %s not found\n", app.out.DiffSprintf(groupName))
+				app.exitCode = 1
+				return
+			}
+			if strings.HasPrefix(groupName, "..") && !clearForce {
+				output.Printf("Refusing to clear ignored group %s (use --force)\n", app.out.DiffSprintf(groupName))
+				app.exitCode = 1
+				return
+			}
+		}
+
+		newEnv := app.baseEnv.Clone()
+		verb := "Removed"
+		if dryRun {
+			verb = "Would remove"
+		}
+		for _, groupName := range args {
+			envs := groupVariables(groupName)
+			if len(envs) == 0 {
+				output.Printf("Group %s is already empty\n", app.out.GroupSprintf(groupName))
+				continue
+			}
+			names := make([]string, len(envs))
+			for i, env := range envs {
+				newEnv.SetNil(env)
+				names[i] = app.out.EnvNameSprintf("%s", env)
+			}
+			output.Printf("%s %s from %s: %s\n", verb, variableCount(len(envs)), app.out.GroupSprintf(groupName), strings.Join(names, ", "))
+		}
+		applyEnvironment(newEnv)
+	},
+}
+
+// groupVariables returns every variable in the environment matching the patterns of the group,
+// also those listed under another group because of group_precedence.
+func groupVariables(groupName string) data.Envs {
+	patterns, _ := app.configuration.Groups.GetPatterns(groupName)
+	envs := make(data.Envs, 0)
+	for _, name := range app.baseEnv.SortedNames(false) {
+		if data.MatchAny(name, patterns, app.caseInsensitive) {
+			envs = append(envs, name)
+		}
+	}
+	return envs
+}
+
+func init() {
+	clearCmd.Flags().BoolVarP(&clearForce, "force", "f", false, "Allow clearing ignored (..) groups")
+	addCommand(clearCmd)
+}
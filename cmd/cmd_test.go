@@ -2,13 +2,19 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/spf13/pflag"
 	"github.com/sverrirab/envirou/pkg/config"
+	"github.com/sverrirab/envirou/pkg/output"
+	"github.com/sverrirab/envirou/pkg/shell"
 )
 
 // tp joins path components with the platform path separator.
@@ -20,9 +26,14 @@ const testConfigForCmd = `
 [settings]
 quiet=1
 path=TEST_PATH
+password=TEST_SECRET
 
 [groups]
 test=TEST_*
+..ignore=IGNORED_*
+
+[group:test]
+description=Test variables
 
 [profile:dev]
 TEST_ENV=development
@@ -43,6 +54,44 @@ TEST_PATH+=/opt/tools/bin
 // the root command with the given args. Returns captured stdout.
 func executeCommand(t *testing.T, args ...string) string {
 	t.Helper()
+	out, _ := executeCommandWithStderr(t, args...)
+	return out
+}
+
+// executeCommandWithStderr is executeCommand also returning what was shown to the user on stderr.
+func executeCommandWithStderr(t *testing.T, args ...string) (string, string) {
+	t.Helper()
+	return executeCommandWithConfig(t, testConfigForCmd, args...)
+}
+
+// readScripts returns the bootstrap scripts embedded by main.go.
+func readScripts(t *testing.T) Scripts {
+	t.Helper()
+	read := func(name string) string {
+		content, err := os.ReadFile(filepath.Join("..", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+	return Scripts{
+		Bash:             read("bash/ev.sh"),
+		BashPrompt:       read("bash/prompt.bash"),
+		ZshPrompt:        read("bash/prompt.zsh"),
+		Fish:             read("fish/ev.fish"),
+		Nushell:          read("nushell/ev.nu"),
+		Elvish:           read("elvish/ev.elv"),
+		Tcsh:             read("tcsh/ev.csh"),
+		PowerShell:       read("powershell/ev.ps1"),
+		PowerShellPrompt: read("powershell/prompt.ps1"),
+		Bat:              read("ev.cmd"),
+		Starship:         read("starship/envirou.toml"),
+	}
+}
+
+// executeCommandWithConfig is executeCommandWithStderr using the configuration in configText.
+func executeCommandWithConfig(t *testing.T, configText string, args ...string) (string, string) {
+	t.Helper()
 
 	// Create temp config
 	file, err := os.CreateTemp("", "config")
@@ -52,7 +101,7 @@ func executeCommand(t *testing.T, args ...string) string {
 	name := file.Name()
 	t.Cleanup(func() { os.Remove(name) })
 
-	_, err = file.WriteString(testConfigForCmd)
+	_, err = file.WriteString(configText)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -60,18 +109,22 @@ func executeCommand(t *testing.T, args ...string) string {
 
 	// Reset global state
 	cfgFile = name
-	bashBootstrap = "#!/bin/bash\nfunction ev() { eval \"$(envirou \"$@\")\"; }"
-	powershellBootstrap = "function ev { Invoke-Expression (envirou $args) }"
-	powershellPrompt = "function prompt { \"PS> \" }"
-	batBootstrap = "@FOR /F %%g IN (`envirou %*`) do @%%g"
+	setScripts(readScripts(t))
 	verbose = false
 	noColor = true
 	dryRun = false
 	displayUnformatted = false
+	shellName = ""
+	detectShell = func() (string, string) { return "bash", "test" }
 	outputPowerShell = false
+	outputFish = false
+	outputNushell = false
+	outputElvish = false
+	outputTcsh = false
 	showAllGroups = false
 	actionShowGroups = nil
 	addPrompt = false
+	addCompletion = false
 	showActiveProfilesOnly = false
 	showInactiveProfilesOnly = false
 	snapshotReset = false
@@ -81,6 +134,18 @@ func executeCommand(t *testing.T, args ...string) string {
 	findIgnoreCase = false
 	findRegex = false
 	pathCheck = false
+	clearForce = false
+	suggestMinCount = 2
+	suggestAccept = false
+	outputFormat = output.FormatText
+	reveal = false
+	outputTemplate = ""
+	wide = false
+	setInteractive = false
+	promptNoCache = false
+	execDotenvFiles = nil
+	execClean = false
+	execKeepGroups = nil
 
 	// Reset cobra flag "changed" state so mutually exclusive checks work
 	rootCmd.Flags().VisitAll(func(f *pflag.Flag) { f.Changed = false })
@@ -89,25 +154,38 @@ func executeCommand(t *testing.T, args ...string) string {
 		c.Flags().VisitAll(func(f *pflag.Flag) { f.Changed = false })
 	}
 
-	// Capture stdout (where shell commands are printed)
-	oldStdout := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
+	// Capture stdout (where shell commands are printed) and stderr (shown to the user)
+	stdout, stderr := capture(&os.Stdout), capture(&os.Stderr)
 
 	rootCmd.SetArgs(args)
 	err = rootCmd.Execute()
 
-	w.Close()
-	os.Stdout = oldStdout
-
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
+	errOutput := stderr()
+	out := stdout()
 
 	if err != nil {
 		t.Fatalf("Command %v failed: %v", args, err)
 	}
 
-	return buf.String()
+	return out, errOutput
+}
+
+// capture redirects *f to a pipe until the returned function is called, which returns the output.
+func capture(f **os.File) func() string {
+	old := *f
+	r, w, _ := os.Pipe()
+	*f = w
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.String()
+	}()
+	return func() string {
+		w.Close()
+		*f = old
+		return <-done
+	}
 }
 
 // --- Bootstrap tests ---
@@ -125,7 +203,7 @@ func TestBootstrapBash(t *testing.T) {
 
 func TestBootstrapZsh(t *testing.T) {
 	out := executeCommand(t, "bootstrap", "zsh")
-	if !strings.Contains(out, "function ev()") {
+	if !strings.Contains(out, "function ev()") || !strings.Contains(out, "--shell zsh") {
 		t.Errorf("Expected zsh ev function (same as bash), got: %s", out)
 	}
 }
@@ -141,6 +219,28 @@ func TestBootstrapPowershell(t *testing.T) {
 	}
 }
 
+func TestBootstrapIgnoresSelectedShell(t *testing.T) {
+	for _, args := range [][]string{{"--shell", "nushell", "bootstrap", "bash"}, {"--shell", "fish", "bootstrap", "zsh"}, {"--shell", "nushell", "bootstrap", "bat"}} {
+		out := executeCommand(t, args...)
+		if strings.HasPrefix(out, "{") || strings.Contains(out, "set -gx") {
+			t.Errorf("%v: expected the plain script, got: %s", args, out)
+		}
+	}
+	if out := executeCommand(t, "--shell", "nushell", "bootstrap", "bat"); !strings.HasPrefix(out, batBootstrap) {
+		t.Errorf("Expected the bat script, got: %s", out)
+	}
+	if out := executeCommand(t, "--shell", "nushell", "bootstrap", "bash"); !strings.HasPrefix(out, "function ev()") {
+		t.Errorf("Expected the bash script, got: %s", out)
+	}
+}
+
+func TestCollapseToOneLine(t *testing.T) {
+	script := "function ev {\n    # comment\n    & envirou $args\n\n}\n"
+	if actual := collapseToOneLine(script); actual != "function ev {; & envirou $args; }" {
+		t.Errorf("Incorrect one line script: %s", actual)
+	}
+}
+
 func TestBootstrapPowershellWithPrompt(t *testing.T) {
 	out := executeCommand(t, "bootstrap", "powershell", "--prompt")
 	if !strings.Contains(out, "Invoke-Expression") {
@@ -151,16 +251,64 @@ func TestBootstrapPowershellWithPrompt(t *testing.T) {
 	}
 }
 
+func TestBootstrapBashWithPrompt(t *testing.T) {
+	out := executeCommand(t, "bootstrap", "bash")
+	if strings.Contains(out, "PS1") {
+		t.Error("Prompt should not be included without --prompt flag")
+	}
+	out = executeCommand(t, "bootstrap", "bash", "--prompt")
+	if !strings.Contains(out, "function ev()") || !strings.Contains(out, "}\n__envirou_prompt()") || !strings.Contains(out, "PS1=") {
+		t.Errorf("Expected ev function followed by the prompt on a new line, got: %s", out)
+	}
+}
+
+func TestBootstrapZshWithPrompt(t *testing.T) {
+	out := executeCommand(t, "bootstrap", "zsh", "--prompt")
+	if !strings.Contains(out, "RPROMPT=") || strings.Contains(out, "PS1") {
+		t.Errorf("Expected RPROMPT for zsh, got: %s", out)
+	}
+}
+
+func TestBootstrapStarship(t *testing.T) {
+	out := executeCommand(t, "bootstrap", "starship")
+	if out != starshipModule {
+		t.Errorf("Expected starship module as is, got: %s", out)
+	}
+}
+
+func TestBootstrapFish(t *testing.T) {
+	out := executeCommand(t, "bootstrap", "fish")
+	if !strings.Contains(out, "function ev") || !strings.Contains(out, "--shell fish") {
+		t.Errorf("Expected fish ev function, got: %s", out)
+	}
+	if strings.Contains(out, "#!") {
+		t.Error("Shebang line should be removed")
+	}
+}
+
+func TestBootstrapVerbatim(t *testing.T) {
+	for _, test := range []struct{ shell, script string }{
+		{"nushell", nushellBootstrap},
+		{"elvish", elvishBootstrap},
+		{"tcsh", tcshBootstrap},
+	} {
+		out := executeCommand(t, "bootstrap", test.shell)
+		if out != test.script {
+			t.Errorf("Expected %s script as is, got: %s", test.shell, out)
+		}
+	}
+}
+
 func TestBootstrapBat(t *testing.T) {
 	out := executeCommand(t, "bootstrap", "bat")
-	if !strings.Contains(out, "FOR /F") {
+	if !strings.Contains(out, "ENVIROU_CMD_FILE") || !strings.Contains(out, "--shell bat") {
 		t.Errorf("Expected batch wrapper, got: %s", out)
 	}
 }
 
 func TestBootstrapInvalidArg(t *testing.T) {
 	// Can't use executeCommand because we expect an error
-	rootCmd.SetArgs([]string{"bootstrap", "fish"})
+	rootCmd.SetArgs([]string{"bootstrap", "notashell"})
 	err := rootCmd.Execute()
 	if err == nil {
 		t.Error("Expected error for invalid shell type")
@@ -185,6 +333,49 @@ func TestSetProfile(t *testing.T) {
 	}
 }
 
+func TestSetProfileFish(t *testing.T) {
+	t.Setenv("TEST_ENV", "old_value")
+	t.Setenv("TEST_DEBUG", "1")
+	out := executeCommand(t, "--output-fish", "set", "prod")
+	if !strings.Contains(out, "set -gx TEST_ENV production") || !strings.Contains(out, "set -e TEST_DEBUG") {
+		t.Errorf("Expected fish commands, got: %s", out)
+	}
+}
+
+func TestSetProfileOtherShells(t *testing.T) {
+	t.Setenv("TEST_ENV", "old_value")
+	t.Setenv("TEST_DEBUG", "1")
+	for name, expected := range map[string]string{
+		"nushell":    "{TEST_ENV: 'production', TEST_DEBUG: null}\n",
+		"elvish":     "set-env TEST_ENV production;unset-env TEST_DEBUG\n",
+		"tcsh":       "setenv TEST_ENV production;unsetenv TEST_DEBUG\n",
+		"powershell": "$Env:TEST_ENV = 'production';Remove-Item Env:TEST_DEBUG\n",
+		"bat":        "@set TEST_ENV=production\r\n@set TEST_DEBUG=\r\n",
+	} {
+		out := executeCommand(t, "--shell", name, "set", "prod")
+		if out != expected {
+			t.Errorf("Expected %q with --shell %s, got: %q", expected, name, out)
+		}
+	}
+}
+
+func TestShellDetected(t *testing.T) {
+	t.Setenv("TEST_ENV", "old_value")
+	out, _ := executeCommandWithStderr(t, "set", "dev")
+	if out != "export TEST_ENV=development\n" {
+		t.Errorf("Expected bash output by default, got: %q", out)
+	}
+	detectShell = func() (string, string) { return "fish", "test" }
+	defer func() { detectShell = shell.Detect }()
+	rootCmd.SetArgs([]string{"set", "dev", "-v"})
+	stdout, stderr := capture(&os.Stdout), capture(&os.Stderr)
+	err := rootCmd.Execute()
+	errOutput, out := stderr(), stdout()
+	if err != nil || out != "set -gx TEST_ENV development\n" || !strings.Contains(errOutput, "Shell: fish (from test)") {
+		t.Errorf("Expected detected fish output, got: %q (%s)", out, errOutput)
+	}
+}
+
 func TestSetProfileAlreadyActive(t *testing.T) {
 	t.Setenv("TEST_ENV", "development")
 	out := executeCommand(t, "set", "dev")
@@ -205,10 +396,13 @@ func TestSetMultipleProfiles(t *testing.T) {
 
 func TestSetMissingProfile(t *testing.T) {
 	out := executeCommand(t, "set", "nonexistent")
-	// Should still succeed (exit 0) but no shell commands
+	// Should fail (exit 1) with no shell commands
 	if strings.Contains(out, "export") {
 		t.Errorf("Expected no shell commands for missing profile, got: %s", out)
 	}
+	if app.exitCode != 1 {
+		t.Errorf("Expected exit code 1 for missing profile, got: %d", app.exitCode)
+	}
 }
 
 func TestSetPartialMissing(t *testing.T) {
@@ -218,6 +412,31 @@ func TestSetPartialMissing(t *testing.T) {
 	if !strings.Contains(out, "development") {
 		t.Errorf("Expected dev profile to be applied despite missing profile, got: %s", out)
 	}
+	if app.exitCode != 1 {
+		t.Errorf("Expected exit code 1 for missing profile, got: %d", app.exitCode)
+	}
+}
+
+func TestSetCommandFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "commands")
+	t.Setenv("ENVIROU_CMD_FILE", file)
+	out, errOut := executeCommandWithStderr(t, "set", "dev")
+	commands, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(commands) != "export TEST_ENV=development\n" {
+		t.Errorf("Expected shell commands in the file, got: %q", commands)
+	}
+	if !strings.Contains(out, "Profile dev enabled") || strings.Contains(out, "export") || errOut != "" {
+		t.Errorf("Expected user output on stdout, got: %q and stderr: %q", out, errOut)
+	}
+	if _, found := app.baseEnv.Get("ENVIROU_CMD_FILE"); found {
+		t.Error("Expected ENVIROU_CMD_FILE to be removed from the environment")
+	}
+	if app.exitCode != 0 {
+		t.Errorf("Expected exit code 0, got: %d", app.exitCode)
+	}
 }
 
 // --- Profiles tests ---
@@ -255,8 +474,118 @@ func TestProfilesInactiveOnly(t *testing.T) {
 func TestGroupsList(t *testing.T) {
 	_ = executeCommand(t, "groups")
 	names := app.configuration.Groups.GetAllNames()
-	if len(names) != 1 || names[0] != "test" {
-		t.Errorf("Expected [test] group, got: %v", names)
+	if len(names) != 2 || names[0] != "..ignore" || names[1] != "test" {
+		t.Errorf("Expected [..ignore test] groups, got: %v", names)
+	}
+}
+
+func TestGroupsListOptions(t *testing.T) {
+	_ = executeCommand(t, "groups")
+	if app.configuration.Groups.GetOptions("test").Description != "Test variables" {
+		t.Errorf("Expected group description, got: %+v", app.configuration.Groups.GetOptions("test"))
+	}
+}
+
+func TestRootCommandShowGroup(t *testing.T) {
+	t.Setenv("TEST_VAR", "hello")
+	_, shown := executeCommandWithStderr(t, "-g", "test")
+	if !strings.Contains(shown, "TEST_VAR") {
+		t.Errorf("Expected the test group, got: %s", shown)
+	}
+}
+
+func TestRootCommandGroupsNotDisplayed(t *testing.T) {
+	configText := strings.Replace(testConfigForCmd, "quiet=1", "quiet=0", 1) + `
+[groups]
+.hidden=HIDDEN_*
+.empty=EMPTY_*
+unused=UNUSED_*
+`
+	t.Setenv("TEST_VAR", "hello")
+	t.Setenv("HIDDEN_VAR", "hello")
+	_, shown := executeCommandWithConfig(t, configText)
+	if !strings.Contains(shown, "# Groups not displayed: .hidden (use -a to show all)") {
+		t.Errorf("Expected only the hidden group with matches in the footer, got: %s", shown)
+	}
+	if strings.Contains(shown, "HIDDEN_VAR") {
+		t.Errorf("Hidden group should not be displayed, got: %s", shown)
+	}
+	_, shown = executeCommandWithConfig(t, configText, "-a")
+	if strings.Contains(shown, "Groups not displayed") || !strings.Contains(shown, "HIDDEN_VAR") {
+		t.Errorf("Expected every group with -a, got: %s", shown)
+	}
+}
+
+func TestGroupsSuggestAccept(t *testing.T) {
+	t.Setenv("ZZSPARK_HOME", "/opt/spark")
+	t.Setenv("ZZSPARK_MASTER", "local")
+	_ = executeCommand(t, "groups", "suggest", "--accept", "zzspark")
+	b, err := os.ReadFile(cfgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "zzspark=ZZSPARK_*") {
+		t.Errorf("Expected suggested group in config, got: %s", b)
+	}
+	if !strings.Contains(string(b), "[profile:dev]") {
+		t.Error("Existing config should be preserved")
+	}
+}
+
+// --- Clear tests ---
+
+func TestClearGroup(t *testing.T) {
+	t.Setenv("TEST_ENV", "development")
+	t.Setenv("TEST_OTHER", "value")
+	out := executeCommand(t, "clear", "test")
+	if !strings.Contains(out, "unset TEST_ENV") || !strings.Contains(out, "unset TEST_OTHER") {
+		t.Errorf("Expected TEST_ENV and TEST_OTHER to be unset, got: %s", out)
+	}
+}
+
+func TestClearGroupDryRun(t *testing.T) {
+	t.Setenv("TEST_ENV", "development")
+	out := executeCommand(t, "clear", "--dry-run", "test")
+	if out != "" {
+		t.Errorf("Expected no shell commands with --dry-run, got: %s", out)
+	}
+}
+
+func TestClearIgnoredGroupForced(t *testing.T) {
+	t.Setenv("IGNORED_VAR", "value")
+	out := executeCommand(t, "clear", "--force", "..ignore")
+	if !strings.Contains(out, "unset IGNORED_VAR") {
+		t.Errorf("Expected IGNORED_VAR to be unset, got: %s", out)
+	}
+}
+
+func TestClearIgnoredGroupRefused(t *testing.T) {
+	t.Setenv("IGNORED_VAR", "value")
+	out, shown := executeCommandWithStderr(t, "clear", "..ignore")
+	if out != "" || app.exitCode != 1 || !strings.Contains(shown, "Refusing to clear ignored group") {
+		t.Errorf("Expected ignored group to be refused with exit code 1, got %d: %q %s", app.exitCode, out, shown)
+	}
+}
+
+func TestClearUnknownGroup(t *testing.T) {
+	t.Setenv("TEST_ENV", "development")
+	out, shown := executeCommandWithStderr(t, "clear", "test", "nosuchgroup")
+	if out != "" || app.exitCode != 1 || !strings.Contains(shown, "Group nosuchgroup not found") {
+		t.Errorf("Expected unknown group to fail with exit code 1, got %d: %q %s", app.exitCode, out, shown)
+	}
+}
+
+func TestClearGroupIgnoresPrecedence(t *testing.T) {
+	configText := strings.Replace(testConfigForCmd, "[settings]", "[settings]\ngroup_precedence=order", 1) + `
+[groups]
+basic=AWS_PROFILE
+aws=AWS_*
+`
+	t.Setenv("AWS_PROFILE", "prod")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	out, _ := executeCommandWithConfig(t, configText, "clear", "aws")
+	if !strings.Contains(out, "unset AWS_PROFILE") || !strings.Contains(out, "unset AWS_REGION") {
+		t.Errorf("Expected every variable matching aws to be unset, got: %s", out)
 	}
 }
 
@@ -348,6 +677,22 @@ func TestDotenvMultipleFiles(t *testing.T) {
 	}
 }
 
+func TestDotenvInvalidName(t *testing.T) {
+	name := writeTempEnvFile(t, "GOOD=1\nX;touch /tmp/pwned;Y=1\n")
+	executeCommand(t, "profiles")
+	newEnv := app.baseEnv.Clone()
+	if err := loadDotenvFile(name, newEnv); err != nil {
+		t.Fatal(err)
+	}
+	err := addEnvironmentCommands(newEnv)
+	if err == nil || !strings.Contains(err.Error(), `"X;touch /tmp/pwned;Y"`) || strings.Contains(err.Error(), "GOOD") {
+		t.Errorf("Expected error listing only the invalid name, got: %v", err)
+	}
+	if len(app.shellCommands) != 0 {
+		t.Errorf("Expected no shell commands, got: %v", app.shellCommands)
+	}
+}
+
 // --- Config command tests ---
 
 func TestConfigWithEditor(t *testing.T) {
@@ -359,6 +704,50 @@ func TestConfigWithEditor(t *testing.T) {
 	}
 }
 
+func TestConfigNushell(t *testing.T) {
+	t.Setenv("EDITOR", "vi")
+	out, shown := executeCommandWithStderr(t, "--shell", "nushell", "config")
+	if out != "" {
+		t.Errorf("Expected no record for nushell, got: %s", out)
+	}
+	if !strings.Contains(shown, "can not start the editor") || !strings.Contains(shown, "vi '") || app.exitCode != 1 {
+		t.Errorf("Expected the editor command to be shown and exit code 1, got %d: %s", app.exitCode, shown)
+	}
+	out = executeCommand(t, "--shell", "bash", "config")
+	if !strings.HasPrefix(out, "vi ") {
+		t.Errorf("Expected the editor to be started by bash, got: %s", out)
+	}
+}
+
+// --- Prompt tests ---
+
+func TestPrompt(t *testing.T) {
+	config.RemoveSnapshot()
+	t.Setenv("TEST_ENV", "production")
+	t.Setenv("TEST_DEBUG", "")
+	out := executeCommand(t, "prompt")
+	if out != "" {
+		t.Errorf("Expected empty prompt without active profiles, got: %q", out)
+	}
+
+	os.Unsetenv("TEST_DEBUG")
+	t.Setenv("VIRTUAL_ENV", "/home/user/venv")
+	t.Setenv("TEST_PATH", tp("/home/user/venv/bin", "/usr/bin"))
+	out = executeCommand(t, "prompt")
+	if out != "[prod,venv]\n" {
+		t.Errorf("Expected active profiles, got: %q", out)
+	}
+
+	_ = executeCommand(t, "snapshot")
+	t.Cleanup(func() { config.RemoveSnapshot() })
+	t.Setenv("TEST_ENV", "development")
+	t.Setenv("IGNORED_VAR", "not a change")
+	out = executeCommand(t, "prompt")
+	if out != "[dev,venv*]\n" {
+		t.Errorf("Expected changed marker, got: %q", out)
+	}
+}
+
 // --- Snapshot tests ---
 
 func TestSnapshotCommand(t *testing.T) {
@@ -398,8 +787,14 @@ func TestSnapshotReset(t *testing.T) {
 
 func TestDiffNoSnapshot(t *testing.T) {
 	config.RemoveSnapshot()
-	// Should not error, just print message
-	_ = executeCommand(t, "diff")
+	_, shown := executeCommandWithStderr(t, "diff")
+	if !strings.Contains(shown, "No snapshot found") || app.exitCode != 1 {
+		t.Errorf("Expected message and exit code 1, got %d: %s", app.exitCode, shown)
+	}
+	out := executeCommand(t, "diff", "-o", "json")
+	if strings.Join(strings.Fields(out), "") != `{"added":[],"changed":[],"removed":[]}` || app.exitCode != 1 {
+		t.Errorf("Expected an empty diff and exit code 1, got %d: %s", app.exitCode, out)
+	}
 }
 
 func TestDiffWithChanges(t *testing.T) {
@@ -529,3 +924,444 @@ func TestPathCheck(t *testing.T) {
 	_ = executeCommand(t, "path", "--check", "TEST_PATH")
 	// Should flag the missing dir and the duplicate
 }
+
+// --- Structured output tests ---
+
+// decodeJSON unmarshals command output, failing the test if it is not valid JSON.
+func decodeJSON(t *testing.T, out string, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(out), v); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, out)
+	}
+}
+
+func TestRootCommandJSON(t *testing.T) {
+	t.Setenv("TEST_ENV", "development")
+	t.Setenv("TEST_SECRET", "hunter2")
+	out := executeCommand(t, "-o", "json", "-g", "test")
+	var listing struct {
+		Groups []output.Group `json:"groups"`
+	}
+	decodeJSON(t, out, &listing)
+	if len(listing.Groups) != 1 || listing.Groups[0].Name != "test" {
+		t.Fatalf("Expected only the test group, got: %s", out)
+	}
+	values := make(map[string]output.Variable)
+	for _, v := range listing.Groups[0].Variables {
+		values[v.Name] = v
+	}
+	if values["TEST_ENV"].Value != "development" {
+		t.Errorf("Expected TEST_ENV value, got: %s", out)
+	}
+	if secret := values["TEST_SECRET"]; !secret.Masked || secret.Value == "hunter2" {
+		t.Errorf("Expected TEST_SECRET to be masked, got: %s", out)
+	}
+}
+
+func TestRootCommandReveal(t *testing.T) {
+	t.Setenv("TEST_SECRET", "hunter2")
+	out := executeCommand(t, "-o", "json", "--reveal", "-g", "test")
+	if !strings.Contains(out, "hunter2") {
+		t.Errorf("Expected revealed value, got: %s", out)
+	}
+}
+
+func TestRootCommandYAML(t *testing.T) {
+	t.Setenv("TEST_ENV", "development")
+	out := executeCommand(t, "--output", "yaml", "-g", "test")
+	if !strings.HasPrefix(out, "groups:\n") || !strings.Contains(out, `value: "development"`) {
+		t.Errorf("Unexpected YAML output: %s", out)
+	}
+}
+
+func TestProfilesJSON(t *testing.T) {
+	t.Setenv("TEST_ENV", "development")
+	out := executeCommand(t, "profiles", "-o", "json")
+	var listing struct {
+		Profiles []output.ProfileState `json:"profiles"`
+	}
+	decodeJSON(t, out, &listing)
+	active := make(map[string]bool)
+	for _, p := range listing.Profiles {
+		active[p.Name] = p.Active
+	}
+	if len(active) != 4 || !active["dev"] || active["prod"] {
+		t.Errorf("Unexpected profiles: %s", out)
+	}
+}
+
+func TestGroupsJSON(t *testing.T) {
+	out := executeCommand(t, "groups", "-o", "json")
+	var listing groupListing
+	decodeJSON(t, out, &listing)
+	found := false
+	for _, g := range listing.Groups {
+		if g.Name == "test" {
+			found = true
+			if g.Description != "Test variables" || len(g.Patterns) != 1 {
+				t.Errorf("Unexpected test group: %+v", g)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected test group, got: %s", out)
+	}
+}
+
+func TestFindJSON(t *testing.T) {
+	t.Setenv("ZZSPARK_HOME", "/opt/spark")
+	out := executeCommand(t, "find", "ZZSPARK", "-o", "json")
+	var result findResult
+	decodeJSON(t, out, &result)
+	if len(result.Variables) != 1 || result.Variables[0].Value != "/opt/spark" {
+		t.Errorf("Unexpected find result: %s", out)
+	}
+}
+
+func TestPathCheckJSON(t *testing.T) {
+	t.Setenv("TEST_PATH", tp(os.TempDir(), "/nonexistent_path_zzz", os.TempDir()))
+	out := executeCommand(t, "path", "--check", "-o", "json", "TEST_PATH")
+	var listing pathListing
+	decodeJSON(t, out, &listing)
+	if len(listing.Paths) != 1 {
+		t.Fatalf("Expected one path variable, got: %s", out)
+	}
+	path := listing.Paths[0]
+	if len(path.Entries) != 3 || path.Duplicates != 1 || path.Missing != 1 {
+		t.Errorf("Unexpected path result: %s", out)
+	}
+	if len(path.Entries[1].Issues) != 1 || path.Entries[1].Issues[0] != "not found" {
+		t.Errorf("Expected missing entry to be flagged, got: %s", out)
+	}
+}
+
+// --- Template output tests ---
+
+func TestRootCommandFormat(t *testing.T) {
+	t.Setenv("TEST_ENV", "development")
+	t.Setenv("TEST_SECRET", "hunter2")
+	out := executeCommand(t, "-g", "test", "--format", `{{range .Vars}}{{.Name}}\t{{.Group}}\t{{.Value}}\t{{join .Profiles ","}}\n{{end}}`)
+	if !strings.Contains(out, "TEST_ENV\ttest\tdevelopment\tdev,prod\n") {
+		t.Errorf("Expected TEST_ENV row, got: %q", out)
+	}
+	if strings.Contains(out, "hunter2") || !strings.Contains(out, "TEST_SECRET\ttest\t"+output.MaskedValue) {
+		t.Errorf("Expected masked TEST_SECRET, got: %q", out)
+	}
+}
+
+func TestFindFormat(t *testing.T) {
+	t.Setenv("TEST_PATH", tp("/usr/bin", "/bin"))
+	out := executeCommand(t, "find", "TEST_PATH", "--name", "--format", "{{range .Vars}}{{.Name}} {{.Path}} {{join .Profiles \",\"}}{{end}}")
+	if out != "TEST_PATH true tools,venv\n" {
+		t.Errorf("Unexpected find output: %q", out)
+	}
+}
+
+func TestProfilesFormat(t *testing.T) {
+	t.Setenv("TEST_ENV", "production")
+	out := executeCommand(t, "profiles", "--active", "--format", "{{range .Profiles}}{{.Name}}{{end}}:{{range .Vars}}{{.Name}}={{.Value}}{{end}}")
+	if out != "prod:TEST_ENV=production\n" {
+		t.Errorf("Unexpected profiles output: %q", out)
+	}
+}
+
+func TestDiffFormat(t *testing.T) {
+	t.Setenv("TEST_DIFF", "before")
+	t.Setenv("TEST_GONE", "removed")
+	_ = executeCommand(t, "snapshot")
+	t.Cleanup(func() { config.RemoveSnapshot() })
+
+	t.Setenv("TEST_DIFF", "after")
+	os.Unsetenv("TEST_GONE")
+	out := executeCommand(t, "diff", "--format", `{{range .Vars}}{{.Status}} {{.Name}}={{.Value}}\n{{end}}`)
+	if !strings.Contains(out, "changed TEST_DIFF=after\n") || !strings.Contains(out, "removed TEST_GONE=\n") {
+		t.Errorf("Unexpected diff output: %q", out)
+	}
+}
+
+// --- Masking tests ---
+
+func TestDryRunMasksSecrets(t *testing.T) {
+	file, err := os.CreateTemp("", "env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(file.Name()) })
+	_, _ = file.WriteString("TEST_SECRET=hunter2\nTEST_PLAIN=visible\n")
+	file.Close()
+
+	out, errOutput := executeCommandWithStderr(t, "dotenv", "--verbose", file.Name())
+	if !strings.Contains(out, "hunter2") {
+		t.Errorf("Shell commands must contain the real value, got: %s", out)
+	}
+	if strings.Contains(errOutput, "hunter2") || !strings.Contains(errOutput, "TEST_PLAIN=visible") {
+		t.Errorf("Expected masked verbose output, got: %s", errOutput)
+	}
+
+	_, errOutput = executeCommandWithStderr(t, "dotenv", "--dry-run", "--reveal", file.Name())
+	if !strings.Contains(errOutput, "hunter2") {
+		t.Errorf("Expected revealed dry-run output, got: %s", errOutput)
+	}
+}
+
+func TestDiffAndFindMaskSecrets(t *testing.T) {
+	t.Setenv("TEST_SECRET", "before")
+	_ = executeCommand(t, "snapshot")
+	t.Cleanup(func() { config.RemoveSnapshot() })
+
+	t.Setenv("TEST_SECRET", "hunter2")
+	_, errOutput := executeCommandWithStderr(t, "diff")
+	if strings.Contains(errOutput, "hunter2") || !strings.Contains(errOutput, "TEST_SECRET") {
+		t.Errorf("Expected masked diff, got: %s", errOutput)
+	}
+	_, errOutput = executeCommandWithStderr(t, "find", "TEST_SECRET")
+	if strings.Contains(errOutput, "hunter2") {
+		t.Errorf("Expected masked find, got: %s", errOutput)
+	}
+	_, errOutput = executeCommandWithStderr(t, "find", "--reveal", "TEST_SECRET")
+	if !strings.Contains(errOutput, "hunter2") {
+		t.Errorf("Expected revealed find, got: %s", errOutput)
+	}
+}
+
+// --- UI tests ---
+
+// scriptedTerminal returns one scripted read per call and discards what is drawn.
+type scriptedTerminal struct {
+	reads []string
+}
+
+func (s *scriptedTerminal) Read(p []byte) (int, error) {
+	if len(s.reads) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, s.reads[0])
+	s.reads = s.reads[1:]
+	return n, nil
+}
+
+func (s *scriptedTerminal) Write(p []byte) (int, error) { return len(p), nil }
+func (s *scriptedTerminal) Size() (int, int)            { return 80, 24 }
+func (s *scriptedTerminal) Close() error                { return nil }
+
+func executeUI(t *testing.T, reads ...string) string {
+	t.Helper()
+	open := openTerminal
+	openTerminal = func() (terminal, error) { return &scriptedTerminal{reads: reads}, nil }
+	defer func() { openTerminal = open }()
+	return executeCommand(t, "ui")
+}
+
+func TestUIToggleProfiles(t *testing.T) {
+	t.Setenv("TEST_ENV", "development")
+	t.Setenv("TEST_PATH", "/usr/bin")
+	// Profiles are sorted dev, prod, tools, venv. Toggling prod twice restores TEST_ENV.
+	out := executeUI(t, "\t", "\x1b[B", " ", " ", "\x1b[B", " ", "q")
+	if !strings.Contains(out, "TEST_PATH") || !strings.Contains(out, "/opt/tools/bin") {
+		t.Errorf("Expected the tools profile to be applied, got: %s", out)
+	}
+	if strings.Contains(out, "TEST_ENV") {
+		t.Errorf("TEST_ENV should be unchanged, got: %s", out)
+	}
+}
+
+func TestUICancel(t *testing.T) {
+	t.Setenv("TEST_ENV", "development")
+	if out := executeUI(t, "\t", "\x1b[B", " ", "\x03"); out != "" {
+		t.Errorf("Ctrl-C should not change anything, got: %s", out)
+	}
+}
+
+// --- Completion tests ---
+
+func TestCompleteProfiles(t *testing.T) {
+	t.Setenv("TEST_ENV", "development")
+	out := executeCommand(t, "__complete", "set", "")
+	// Inactive profiles first, in order
+	if out != "prod\ntools\nvenv\ndev\tactive\n:36\n" {
+		t.Errorf("Unexpected profile completions: %q", out)
+	}
+	out = executeCommand(t, "__complete", "set", "prod", "t")
+	if out != "tools\n:36\n" {
+		t.Errorf("Unexpected profile completions: %q", out)
+	}
+}
+
+func TestCompleteGroups(t *testing.T) {
+	for _, args := range [][]string{{"__complete", "clear", ""}, {"__complete", "-g", ""}} {
+		out := executeCommand(t, args...)
+		if !strings.Contains(out, "test\tTest variables\n") || !strings.HasSuffix(out, ":4\n") {
+			t.Errorf("Expected group completions for %v, got: %q", args, out)
+		}
+	}
+	if out := executeCommand(t, "__complete", "-g", "test", "-g", ""); strings.Contains(out, "test\t") {
+		t.Errorf("Expected group already given to be left out, got: %q", out)
+	}
+}
+
+func TestCompleteVariables(t *testing.T) {
+	t.Setenv("TEST_COMPLETE", "1")
+	t.Setenv("TEST_PATH", tp("/usr/bin", "/bin"))
+	if out := executeCommand(t, "__complete", "find", "TEST_C"); out != "TEST_COMPLETE\n:4\n" {
+		t.Errorf("Unexpected variable completions: %q", out)
+	}
+	if out := executeCommand(t, "__complete", "path", "TEST_"); out != "TEST_PATH\n:4\n" {
+		t.Errorf("Expected only path variables, got: %q", out)
+	}
+}
+
+func TestBootstrapCompletion(t *testing.T) {
+	for shellName, expected := range map[string]string{
+		"bash":       "complete -o default -F __start_envirou ev",
+		"zsh":        "compdef _envirou ev",
+		"fish":       "complete -c ev -w envirou",
+		"powershell": "Register-ArgumentCompleter -CommandName 'ev'",
+	} {
+		out := executeCommand(t, "bootstrap", shellName, "--completion")
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %s completion to contain %q, got: %s", shellName, expected, out)
+		}
+	}
+	if out := executeCommand(t, "bootstrap", "bash"); strings.Contains(out, "__start_envirou") {
+		t.Error("Completion should not be included without --completion flag")
+	}
+	if _, err := completionScript("bat"); err == nil {
+		t.Error("Expected completion for bat to fail")
+	}
+}
+
+// --- Exec tests ---
+
+// TestExecHelperProcess is run as the command of the exec tests.
+// TestMain lets the test binary act as the shell started by the shell command, which is
+// started without arguments so a helper test can not be selected.
+func TestMain(m *testing.M) {
+	if os.Getenv("ENVIROU_TEST_SUBSHELL") == "1" {
+		fmt.Printf("ENVIROU_SUBSHELL=%s TEST_ENV=%s\n", os.Getenv("ENVIROU_SUBSHELL"), os.Getenv("TEST_ENV"))
+		os.Exit(4)
+	}
+	if os.Getenv("ENVIROU_TEST_MAIN") == "1" {
+		// Run as the envirou executable for the wrappers started by the tests
+		Execute(Scripts{})
+	}
+	os.Exit(m.Run())
+}
+
+func TestExecHelperProcess(t *testing.T) {
+	if os.Getenv("ENVIROU_TEST_HELPER") != "1" {
+		return
+	}
+	fmt.Printf("TEST_ENV=%s X=%s\n", os.Getenv("TEST_ENV"), os.Getenv("X"))
+	os.Exit(3)
+}
+
+func TestExec(t *testing.T) {
+	t.Setenv("ENVIROU_TEST_HELPER", "1")
+	t.Setenv("TEST_ENV", "unchanged")
+	name := writeTempEnvFile(t, "X=from_dotenv\n")
+	out := executeCommand(t, "exec", "prod", "dev", "--dotenv", name, "--", os.Args[0], "-test.run=TestExecHelperProcess")
+	if out != "TEST_ENV=development X=from_dotenv\n" {
+		t.Errorf("Expected the command to see the profiles, got: %q", out)
+	}
+	if app.exitCode != 3 {
+		t.Errorf("Expected exit code of the command, got: %d", app.exitCode)
+	}
+	if len(app.shellCommands) != 0 || os.Getenv("TEST_ENV") != "unchanged" {
+		t.Errorf("Expected the current environment to be unchanged, got: %v", app.shellCommands)
+	}
+}
+
+func TestExecCommandNotFound(t *testing.T) {
+	_, errOut := executeCommandWithStderr(t, "exec", "dev", "--", "envirou-no-such-command")
+	if app.exitCode != 127 || !strings.Contains(errOut, "command not found") {
+		t.Errorf("Expected command not found (127), got %d: %s", app.exitCode, errOut)
+	}
+}
+
+// --- Subshell tests ---
+
+func TestProfilesSubshells(t *testing.T) {
+	t.Setenv("ENVIROU_SUBSHELL", "dev>prod,venv")
+	_, errOut := executeCommandWithStderr(t, "profiles")
+	if !strings.Contains(errOut, "Subshells: dev > prod,venv") {
+		t.Errorf("Expected nested subshells in profile list, got: %s", errOut)
+	}
+	out := executeCommand(t, "profiles", "-o", "json")
+	var listing struct {
+		Subshells []string `json:"subshells"`
+	}
+	decodeJSON(t, out, &listing)
+	if strings.Join(listing.Subshells, "|") != "dev|prod,venv" {
+		t.Errorf("Unexpected subshells: %s", out)
+	}
+}
+
+func TestSubshellProgram(t *testing.T) {
+	t.Setenv("SHELL", "/usr/local/bin/fish")
+	executeCommand(t, "profiles")
+	if program := subshellProgram(); program != "/usr/local/bin/fish" {
+		t.Errorf("Expected $SHELL, got: %s", program)
+	}
+	executeCommand(t, "--shell", "nushell", "profiles")
+	if program := subshellProgram(); program != "nu" {
+		t.Errorf("Expected --shell to select nu, got: %s", program)
+	}
+	executeCommand(t, "--shell", "powershell", "profiles")
+	if program := subshellProgram(); runtime.GOOS != "windows" && program != "pwsh" {
+		t.Errorf("Expected pwsh outside Windows, got: %s", program)
+	}
+}
+
+func TestSubshell(t *testing.T) {
+	t.Setenv("SHELL", os.Args[0])
+	t.Setenv("ENVIROU_TEST_SUBSHELL", "1")
+	t.Setenv("TEST_ENV", "old_value")
+	out := executeCommand(t, "shell", "dev", "venv")
+	if out != "ENVIROU_SUBSHELL=dev,venv TEST_ENV=development\n" {
+		t.Errorf("Expected the profiles in the subshell, got: %q", out)
+	}
+	if app.exitCode != 4 {
+		t.Errorf("Expected exit code of the subshell, got: %d", app.exitCode)
+	}
+	// Started from within a subshell
+	t.Setenv("ENVIROU_SUBSHELL", "dev,venv")
+	out = executeCommand(t, "shell", "prod")
+	if out != "ENVIROU_SUBSHELL=dev,venv>prod TEST_ENV=production\n" {
+		t.Errorf("Expected nested subshells, got: %q", out)
+	}
+}
+
+func TestExecClean(t *testing.T) {
+	// The clean environment only has the helper variable from the .env file
+	helper := writeTempEnvFile(t, "ENVIROU_TEST_HELPER=1\n")
+	t.Setenv("TEST_ENV", "stray")
+	t.Setenv("X", "stray")
+	out := executeCommand(t, "exec", "--clean", "--keep", "test", "--dotenv", helper, "--", os.Args[0], "-test.run=TestExecHelperProcess")
+	if out != "TEST_ENV=stray X=\n" {
+		t.Errorf("Expected only the kept group, got: %q", out)
+	}
+	out = executeCommand(t, "exec", "--clean", "--dotenv", helper, "dev", "--", os.Args[0], "-test.run=TestExecHelperProcess")
+	if out != "TEST_ENV=development X=\n" {
+		t.Errorf("Expected only the profile, got: %q", out)
+	}
+}
+
+func TestExecKeepWithoutClean(t *testing.T) {
+	// Reset the flags, can't use executeCommand because we expect an error
+	_ = executeCommand(t, "profiles")
+	rootCmd.SetArgs([]string{"exec", "--keep", "basic", "--", "true"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--keep can only be used with --clean") {
+		t.Errorf("Expected --keep without --clean to fail, got: %v", err)
+	}
+	if _, found := keepGroups(app.baseEnv, []string{"nosuchgroup"}); found {
+		t.Error("Expected unknown group to fail")
+	}
+}
+
+func TestCompleteKeepFlag(t *testing.T) {
+	out := executeCommand(t, "__complete", "exec", "--clean", "--keep", "test,")
+	if !strings.HasPrefix(out, "test,..ignore\n") || strings.Contains(out, "test,test") {
+		t.Errorf("Unexpected --keep completions: %q", out)
+	}
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sverrirab/envirou/pkg/output"
+)
+
+// isCompletionCommand returns true for cobra's __complete command and the completion scripts.
+func isCompletionCommand(cmd *cobra.Command) bool {
+	return cmd.Name() == cobra.ShellCompRequestCmd || cmd.HasParent() && cmd.Parent().Name() == "completion"
+}
+
+// setCommandOutput sets where cobra writes help, completion candidates and scripts for all commands.
+func setCommandOutput(command *cobra.Command, f *os.File) {
+	command.SetOut(f)
+	for _, child := range command.Commands() {
+		setCommandOutput(child, f)
+	}
+}
+
+// initCompletion reads the configuration for a completion function. It runs after cobra parsed
+// the flags so --config is used.
+func initCompletion() {
+	initConfig()
+	// Stdout is for the completion candidates
+	output.UseStdout(false)
+}
+
+// filterCompletions returns the names starting with toComplete that are not in args.
+func filterCompletions(names, args []string, toComplete string) []string {
+	completions := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) && !contains(args, name) {
+			completions = append(completions, name)
+		}
+	}
+	return completions
+}
+
+// completeProfiles completes profile names, the inactive profiles first.
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	initCompletion()
+	completions := filterCompletions(app.inactiveProfileNames, args, toComplete)
+	for _, name := range filterCompletions(app.activeProfileNames, args, toComplete) {
+		completions = append(completions, name+"\tactive")
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+// completeGroups completes group names with their description.
+func completeGroups(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	initCompletion()
+	groups := &app.configuration.Groups
+	completions := filterCompletions(groups.GetDisplayNames(), args, toComplete)
+	for i, name := range completions {
+		if description := groups.GetOptions(name).Description; description != "" {
+			completions[i] = name + "\t" + description
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeGroupFlag completes the values of the --group flag.
+func completeGroupFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	groups, _ := cmd.Flags().GetStringArray("group")
+	return completeGroups(cmd, groups, toComplete)
+}
+
+// completeVariables completes the names of the variables in the current environment.
+func completeVariables(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	initCompletion()
+	return filterCompletions(app.baseEnv.SortedNames(false), nil, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePathVariables completes the names of the path-like variables in the current environment.
+func completePathVariables(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	initCompletion()
+	names := make([]string, 0)
+	for _, name := range app.baseEnv.SortedNames(false) {
+		if app.out.IsPathVariable(name) {
+			names = append(names, name)
+		}
+	}
+	return filterCompletions(names, nil, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completionScript returns cobra's completion script for the shell, also completing the ev
+// function. The scripts call the command being completed, so completing ev runs the ev
+// function which passes the request on to envirou.
+func completionScript(shellName string) (string, error) {
+	var b bytes.Buffer
+	var err error
+	switch shellName {
+	case "bash":
+		err = rootCmd.GenBashCompletionV2(&b, true)
+		b.WriteString(`
+if [[ $(type -t compopt) = "builtin" ]]; then
+    complete -o default -F __start_envirou ev
+else
+    complete -o default -o nospace -F __start_envirou ev
+fi
+`)
+	case "zsh":
+		err = rootCmd.GenZshCompletion(&b)
+		b.WriteString("compdef _envirou ev\n")
+	case "fish":
+		err = rootCmd.GenFishCompletion(&b, true)
+		b.WriteString("complete -c ev -w envirou\n")
+	case "powershell":
+		// The script can not be collapsed to one line, so it is evaluated separately
+		b.WriteString("Invoke-Expression ((& envirou completion powershell) -join \"`n\")\n")
+		b.WriteString("Register-ArgumentCompleter -CommandName 'ev' -ScriptBlock ${__envirouCompleterBlock}\n")
+	default:
+		return "", fmt.Errorf("completion is not supported for %s (use bash, zsh, fish or powershell)", shellName)
+	}
+	return b.String(), err
+}
+
+// completeExec completes profile names before -- and files after it.
+func completeExec(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if cmd.ArgsLenAtDash() >= 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return completeProfiles(cmd, args, toComplete)
+}
+
+// completeKeepFlag completes the comma separated groups of the --keep flag of exec.
+func completeKeepFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	groups, _ := cmd.Flags().GetStringSlice("keep")
+	prefix := toComplete[:strings.LastIndex(toComplete, ",")+1]
+	groups = append(groups, strings.Split(prefix, ",")...)
+	completions, directive := completeGroups(cmd, groups, toComplete[len(prefix):])
+	for i := range completions {
+		completions[i] = prefix + completions[i]
+	}
+	return completions, directive | cobra.ShellCompDirectiveNoSpace
+}
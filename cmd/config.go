@@ -22,8 +22,14 @@ var configCmd = &cobra.Command{
 			output.Printf("Configuration file location: %s\n", config.GetDefaultConfigFilePath())
 			os.Exit(3)
 		}
-		output.Printf("Launching EDITOR ...\n")
 		configFile := config.GetDefaultConfigFilePath()
+		if !app.sh.CanRunCommands() {
+			output.Printf("The ev function for %s can not start the editor, run it yourself:\n", app.shellName)
+			output.Printf("%s %s\n", editor, app.sh.Escape(configFile))
+			app.exitCode = 1
+			return
+		}
+		output.Printf("Launching EDITOR ...\n")
 		app.shellCommands = append(app.shellCommands, fmt.Sprintf("%s \"%s\"", editor, configFile))
 	},
 }
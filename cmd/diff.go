@@ -2,8 +2,8 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/sverrirab/envirou/pkg/config"
@@ -25,6 +25,13 @@ var diffCmd = &cobra.Command{
 		}
 		if snapshot == nil {
 			output.Printf("No snapshot found. Run %s first.\n", app.out.ProfileSprintf("snapshot"))
+			// Scripts still get a document of the usual shape
+			if templateOutput() {
+				printTemplate(output.TemplateData{Vars: newDiffTemplateVariables(nil, nil, nil), Profiles: newProfileStates(app.profileNames)})
+			} else if structuredOutput() {
+				printStructured(diffResult{Added: newVariables(nil), Changed: newVariables(nil), Removed: []string{}})
+			}
+			app.exitCode = 1
 			return
 		}
 
@@ -35,21 +42,18 @@ var diffCmd = &cobra.Command{
 		changed = filterIgnored(changed, &app.configuration.Groups, app.caseInsensitive)
 		removed = filterIgnored(removed, &app.configuration.Groups, app.caseInsensitive)
 
-		if len(added) == 0 && len(changed) == 0 && len(removed) == 0 {
+		noChanges := len(added) == 0 && len(changed) == 0 && len(removed) == 0
+		if templateOutput() {
+			printTemplate(output.TemplateData{Vars: newDiffTemplateVariables(added, changed, removed), Profiles: newProfileStates(app.profileNames)})
+		} else if structuredOutput() {
+			printStructured(diffResult{Added: newVariables(added), Changed: newVariables(changed), Removed: removed})
+		} else if noChanges {
 			output.Printf("No changes since snapshot\n")
-			return
-		}
-
-		for _, name := range added {
-			value, _ := app.baseEnv.Get(name)
-			output.Printf("%s %s=%s\n", app.out.DiffSprintf("+"), app.out.EnvNameSprintf("%s", name), value)
-		}
-		for _, name := range changed {
-			value, _ := app.baseEnv.Get(name)
-			output.Printf("%s %s=%s\n", app.out.DiffSprintf("~"), app.out.EnvNameSprintf("%s", name), value)
+		} else {
+			printDiff(added, changed, removed)
 		}
-		for _, name := range removed {
-			output.Printf("%s %s\n", app.out.DiffSprintf("-"), app.out.EnvNameSprintf("%s", name))
+		if noChanges {
+			return
 		}
 
 		if diffSaveProfile != "" {
@@ -59,14 +63,8 @@ var diffCmd = &cobra.Command{
 				return
 			}
 
-			f, err := os.OpenFile(cfgFile, os.O_APPEND|os.O_WRONLY, 0644)
-			if err != nil {
-				output.Printf("Failed to open config file: %v\n", err)
-				return
-			}
-			defer f.Close()
-
-			fmt.Fprintf(f, "\n[profile:%s]\n", diffSaveProfile)
+			var b strings.Builder
+			fmt.Fprintf(&b, "\n[profile:%s]\n", diffSaveProfile)
 			// Combine and sort all entries
 			type entry struct {
 				name  string
@@ -88,16 +86,62 @@ var diffCmd = &cobra.Command{
 			sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
 			for _, e := range entries {
 				if e.isNil {
-					fmt.Fprintf(f, "%s\n", e.name)
+					fmt.Fprintf(&b, "%s\n", e.name)
 				} else {
-					fmt.Fprintf(f, "%s=%s\n", e.name, e.value)
+					fmt.Fprintf(&b, "%s=%s\n", e.name, e.value)
 				}
 			}
+			if err := config.AppendFile(cfgFile, []byte(b.String()), 0644); err != nil {
+				output.Printf("Failed to update config file: %v\n", err)
+				return
+			}
 			output.Printf("Saved profile %s\n", app.out.ProfileSprintf(diffSaveProfile))
 		}
 	},
 }
 
+// diffResult is the structured output of the diff command.
+type diffResult struct {
+	Added   []output.Variable `json:"added"`
+	Changed []output.Variable `json:"changed"`
+	Removed []string          `json:"removed"`
+}
+
+// newDiffTemplateVariables returns the --format data for the diff, removed variables have no value.
+func newDiffTemplateVariables(added, changed, removed []string) []output.TemplateVariable {
+	variables := make([]output.TemplateVariable, 0, len(added)+len(changed)+len(removed))
+	for _, list := range []struct {
+		status string
+		names  []string
+	}{{"added", added}, {"changed", changed}, {"removed", removed}} {
+		for _, v := range newTemplateVariables(list.names) {
+			if list.status == "removed" {
+				v.Value, v.Masked = "", false
+			}
+			v.Status = list.status
+			v.Changed = true
+			variables = append(variables, v)
+		}
+	}
+	return variables
+}
+
+func printDiff(added, changed, removed []string) {
+	for _, name := range added {
+		value, _ := app.baseEnv.Get(name)
+		value, _ = app.out.MaskValue(name, value)
+		output.Printf("%s %s=%s\n", app.out.DiffSprintf("+"), app.out.EnvNameSprintf("%s", name), value)
+	}
+	for _, name := range changed {
+		value, _ := app.baseEnv.Get(name)
+		value, _ = app.out.MaskValue(name, value)
+		output.Printf("%s %s=%s\n", app.out.DiffSprintf("~"), app.out.EnvNameSprintf("%s", name), value)
+	}
+	for _, name := range removed {
+		output.Printf("%s %s\n", app.out.DiffSprintf("-"), app.out.EnvNameSprintf("%s", name))
+	}
+}
+
 func filterIgnored(names []string, groups *data.Groups, caseInsensitive bool) []string {
 	result := make([]string, 0, len(names))
 	for _, name := range names {
@@ -111,4 +155,5 @@ func filterIgnored(names []string, groups *data.Groups, caseInsensitive bool) []
 func init() {
 	diffCmd.Flags().StringVarP(&diffSaveProfile, "save", "s", "", "Save diff as a new profile")
 	addCommand(diffCmd)
+	addFormatFlag(diffCmd)
 }
@@ -32,7 +32,7 @@ When multiple files are given, they are loaded in order and later values overrid
 				os.Exit(1)
 			}
 		}
-		app.shellCommands = append(app.shellCommands, app.sh.GetCommands(app.baseEnv, newEnv)...)
+		applyEnvironment(newEnv)
 	},
 }
 
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/sverrirab/envirou/pkg/data"
+	"github.com/sverrirab/envirou/pkg/output"
+)
+
+var (
+	execDotenvFiles []string
+	execClean       bool
+	execKeepGroups  []string
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec [PROFILE]... -- COMMAND [ARG]...",
+	Short: "Run a command with profiles applied",
+	Long: `Run a single command with the profiles applied, without changing the current shell.
+
+The profiles are applied in order, then the .env files given with --dotenv.
+Input and output are passed through and the exit code of the command is returned.
+
+With --clean the command starts from an empty environment, keeping only the variables
+in the groups given with --keep, to find dependencies on stray variables:
+
+  ev exec aws-prod -- terraform plan
+  ev exec --clean --keep basic,.locale aws -- make test`,
+	GroupID:           "profiles",
+	ValidArgsFunction: completeExec,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if dash := cmd.ArgsLenAtDash(); dash < 0 || dash == len(args) {
+			return fmt.Errorf("no command given, use -- COMMAND after the profiles")
+		}
+		if len(execKeepGroups) > 0 && !execClean {
+			return fmt.Errorf("--keep can only be used with --clean")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		dash := cmd.ArgsLenAtDash()
+		base := app.baseEnv
+		if execClean {
+			var ok bool
+			if base, ok = keepGroups(app.baseEnv, execKeepGroups); !ok {
+				os.Exit(1)
+			}
+		}
+		newEnv, ok := newProfileEnvironment(base, args[:dash], execDotenvFiles)
+		if !ok {
+			os.Exit(1)
+		}
+		app.exitCode = runCommand(args[dash:], newEnv)
+	},
+}
+
+// newProfileEnvironment returns a copy of base with the profiles and then the .env files
+// applied. Errors are shown to the user and false returned.
+func newProfileEnvironment(base *data.Profile, profileNames, dotenvFiles []string) (*data.Profile, bool) {
+	newEnv := base.Clone()
+	for _, name := range profileNames {
+		profile, found := findProfile(app.out, app.configuration, name)
+		if !found {
+			return nil, false
+		}
+		newEnv.Merge(profile)
+	}
+	for _, filename := range dotenvFiles {
+		if err := loadDotenvFile(filename, newEnv); err != nil {
+			output.Printf("%s: %s\n", filename, err.Error())
+			return nil, false
+		}
+	}
+	return newEnv, true
+}
+
+// keepGroups returns a new environment with only the variables of env in the groups.
+// An unknown group is shown to the user and false returned.
+func keepGroups(env *data.Profile, groupNames []string) (*data.Profile, bool) {
+	groups := &app.configuration.Groups
+	matches, _ := groups.MatchAll(env.SortedNames(false), app.caseInsensitive)
+	kept := data.NewProfile(app.caseInsensitive)
+	for _, groupName := range groupNames {
+		if _, found := groups.GetPatterns(groupName); !found {
+			output.Printf("Group %s not found\n", app.out.DiffSprintf(groupName))
+			return nil, false
+		}
+		for _, name := range matches[groupName] {
+			value, _ := env.Get(name)
+			kept.Set(name, value)
+		}
+	}
+	if verbose {
+		output.Printf("Clean environment keeping %s\n", strings.Join(kept.SortedNames(false), ", "))
+	}
+	return kept, true
+}
+
+// runCommand runs command with the environment env, passing through stdin, stdout and stderr,
+// and returns the exit code. Like a shell it returns 127 if the command is not found,
+// 126 if it can not be started and 128 plus the signal number if it was killed.
+func runCommand(command []string, env *data.Profile) int {
+	path, err := lookPath(command[0], env)
+	if err != nil {
+		output.Printf("%s: command not found\n", command[0])
+		return 127
+	}
+	child := exec.Command(path, command[1:]...)
+	child.Args[0] = command[0]
+	child.Env = env.Environ()
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+	// There are no shell commands, and the ev wrapper must not wait for the command to close the channel
+	closeCommandChannel()
+
+	// Ctrl-C goes to the command, envirou waits for it to exit
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	err = child.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	} else if err != nil {
+		output.Printf("%s: %v\n", command[0], err)
+		return 126
+	}
+	return 0
+}
+
+// lookPath finds the command in the PATH of env rather than the current one, so a profile
+// changing PATH selects the command.
+func lookPath(name string, env *data.Profile) (string, error) {
+	current, hasCurrent := os.LookupEnv("PATH")
+	defer func() {
+		if hasCurrent {
+			_ = os.Setenv("PATH", current)
+		} else {
+			_ = os.Unsetenv("PATH")
+		}
+	}()
+	path, _ := env.Get("PATH")
+	_ = os.Setenv("PATH", path)
+	return exec.LookPath(name)
+}
+
+func init() {
+	execCmd.Flags().StringArrayVar(&execDotenvFiles, "dotenv", nil, "Also load a .env file (can be repeated)")
+	execCmd.Flags().BoolVar(&execClean, "clean", false, "Start from an empty environment")
+	execCmd.Flags().StringSliceVar(&execKeepGroups, "keep", nil, "Groups to keep with --clean, comma separated")
+	_ = execCmd.RegisterFlagCompletionFunc("keep", completeKeepFlag)
+	addCommand(execCmd)
+}
@@ -21,8 +21,9 @@ var findCmd = &cobra.Command{
 	Long: `Search environment variable names and values for a substring or regex match.
 
 By default both names and values are searched. Use --name or --value to restrict.`,
-	GroupID: "profiles",
-	Args:    cobra.ExactArgs(1),
+	GroupID:           "profiles",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeVariables,
 	Run: func(cmd *cobra.Command, args []string) {
 		searchPattern := args[0]
 		caseInsensitive := app.caseInsensitive || findIgnoreCase
@@ -37,7 +38,7 @@ By default both names and values are searched. Use --name or --value to restrict
 		searchName := !findValueOnly
 		searchValue := !findNameOnly
 
-		count := 0
+		found := make([]string, 0)
 		for _, name := range app.baseEnv.SortedNames(false) {
 			value, _ := app.baseEnv.Get(name)
 			matched := false
@@ -48,16 +49,29 @@ By default both names and values are searched. Use --name or --value to restrict
 				matched = true
 			}
 			if matched {
-				app.out.PrintEnv(app.sh, name, value)
-				count++
+				found = append(found, name)
 			}
 		}
-		if count == 0 {
+		if templateOutput() {
+			printTemplate(output.TemplateData{Vars: newTemplateVariables(found), Profiles: newProfileStates(app.profileNames)})
+			return
+		}
+		if structuredOutput() {
+			printStructured(findResult{Variables: newVariables(found)})
+			return
+		}
+		if len(found) == 0 {
 			output.Printf("No matches found\n")
 		}
+		app.out.PrintEnvs(app.sh, found, app.baseEnv)
 	},
 }
 
+// findResult is the structured output of the find command.
+type findResult struct {
+	Variables []output.Variable `json:"variables"`
+}
+
 type findMatcher struct {
 	re *regexp.Regexp
 }
@@ -90,4 +104,5 @@ func init() {
 	findCmd.Flags().BoolVarP(&findRegex, "regex", "r", false, "Use regex instead of substring match (quote your pattern to avoid shell expansion)")
 	findCmd.MarkFlagsMutuallyExclusive("name", "value")
 	addCommand(findCmd)
+	addFormatFlag(findCmd)
 }
@@ -1,6 +1,12 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sverrirab/envirou/pkg/config"
+	"github.com/sverrirab/envirou/pkg/data"
 	"github.com/sverrirab/envirou/pkg/output"
 
 	"github.com/spf13/cobra"
@@ -10,15 +16,121 @@ var groupsCmd = &cobra.Command{
 	Use:     "groups",
 	Aliases: []string{"group", "g"},
 	Short:   "List all groups",
-	Long:    `List all the groups defined in the config file`,
+	Long: `List all the groups defined in the config file in display order,
+with the number of matching variables and the group description`,
 	GroupID: "groups",
 	Run: func(cmd *cobra.Command, args []string) {
-		for _, group := range app.configuration.Groups.GetAllNames() {
-			output.Printf(app.out.GroupSprintf("# %s\n", group))
+		groups := &app.configuration.Groups
+		matches, _ := groups.MatchAll(app.baseEnv.SortedNames(false), app.caseInsensitive)
+		if structuredOutput() {
+			listing := groupListing{Groups: make([]groupInfo, 0, groups.Len())}
+			for _, group := range groups.GetDisplayNames() {
+				options := groups.GetOptions(group)
+				patterns, _ := groups.GetPatterns(group)
+				info := groupInfo{
+					Name:        group,
+					Description: options.Description,
+					Order:       options.Order,
+					Hidden:      strings.HasPrefix(group, "."),
+					Ignored:     strings.HasPrefix(group, ".."),
+					Collapsed:   options.Collapsed,
+					Count:       len(matches[group]),
+					Patterns:    make([]string, 0, len(*patterns)),
+				}
+				for _, p := range *patterns {
+					info.Patterns = append(info.Patterns, string(p))
+				}
+				listing.Groups = append(listing.Groups, info)
+			}
+			printStructured(listing)
+			return
+		}
+		for _, group := range groups.GetDisplayNames() {
+			line := fmt.Sprintf("%s (%s)", app.out.GroupSprintf("# %s", group), variableCount(len(matches[group])))
+			if description := groups.GetOptions(group).Description; description != "" {
+				line += " " + description
+			}
+			output.Printf("%s\n", line)
+		}
+	},
+}
+
+// groupListing is the structured output of the groups command.
+type groupListing struct {
+	Groups []groupInfo `json:"groups"`
+}
+
+type groupInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Order       int      `json:"order"`
+	Hidden      bool     `json:"hidden"`
+	Ignored     bool     `json:"ignored"`
+	Collapsed   bool     `json:"collapsed"`
+	Count       int      `json:"count"`
+	Patterns    []string `json:"patterns"`
+}
+
+var (
+	suggestMinCount int
+	suggestAccept   bool
+)
+
+var groupsSuggestCmd = &cobra.Command{
+	Use:   "suggest [NAME] ...",
+	Short: "Suggest groups for ungrouped variables",
+	Long: `Cluster the variables that are not in any group by their common prefix
+(e.g. SPARK_HOME and SPARK_CONF_DIR) and propose [custom] group entries.
+
+Use --accept to add all suggestions to the config file, or name the suggestions
+to add only those. Comments and formatting in the config file are preserved.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, remaining := app.configuration.Groups.MatchAll(app.baseEnv.SortedNames(false), app.caseInsensitive)
+		suggestions := make([]data.GroupSuggestion, 0)
+		for _, suggestion := range data.SuggestGroups(remaining, suggestMinCount) {
+			if _, exists := app.configuration.Groups.GetPatterns(suggestion.Name); exists {
+				continue
+			}
+			if len(args) > 0 && !contains(args, suggestion.Name) {
+				continue
+			}
+			suggestions = append(suggestions, suggestion)
+		}
+		if len(suggestions) == 0 {
+			output.Printf("No group suggestions\n")
+			return
+		}
+
+		entries := make([]string, 0, len(suggestions))
+		for _, suggestion := range suggestions {
+			entry := fmt.Sprintf("%s=%s", suggestion.Name, suggestion.Pattern)
+			entries = append(entries, entry)
+			output.Printf("%s  %s\n", app.out.GroupSprintf("%s", entry), strings.Join(suggestion.Envs, ", "))
 		}
+		if !suggestAccept {
+			output.Printf("Run with --accept to add these to [custom] in %s\n", cfgFile)
+			return
+		}
+		if err := config.AddCustomGroups(cfgFile, entries); err != nil {
+			output.Printf("Failed to update config file: %v\n", err)
+			return
+		}
+		output.Printf("Added %d groups to [custom]\n", len(entries))
 	},
 }
 
+func variableCount(n int) string {
+	if n == 1 {
+		return "1 variable"
+	}
+	return fmt.Sprintf("%d variables", n)
+}
+
 func init() {
 	addCommand(groupsCmd)
+
+	groupsSuggestCmd.Flags().IntVarP(&suggestMinCount, "min", "m", 2, "Minimum number of variables sharing a prefix")
+	groupsSuggestCmd.Flags().BoolVarP(&suggestAccept, "accept", "y", false, "Write the suggestions into the config file")
+	groupsSuggestCmd.SetOut(os.Stderr)
+	groupsCmd.AddCommand(groupsSuggestCmd)
 }
@@ -12,11 +12,12 @@ import (
 var pathCheck bool
 
 var pathCmd = &cobra.Command{
-	Use:     "path [VAR]",
-	Short:   "Display path-like variables with one entry per line",
-	Long:    `Show path-like variables split into individual entries. Use --check to flag missing directories and duplicates.`,
-	GroupID: "profiles",
-	Args:    cobra.MaximumNArgs(1),
+	Use:               "path [VAR]",
+	Short:             "Display path-like variables with one entry per line",
+	Long:              `Show path-like variables split into individual entries. Use --check to flag missing directories and duplicates.`,
+	GroupID:           "profiles",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completePathVariables,
 	Run: func(cmd *cobra.Command, args []string) {
 		var names []string
 		if len(args) == 1 {
@@ -41,75 +42,108 @@ var pathCmd = &cobra.Command{
 			return
 		}
 
-		sep := string(os.PathListSeparator)
+		listing := pathListing{Paths: make([]pathVariable, 0, len(names))}
 		for _, name := range names {
 			value, _ := app.baseEnv.Get(name)
-			parts := strings.Split(value, sep)
-
-			output.Printf("%s\n", app.out.EnvNameSprintf("# %s", name))
-
-			seen := make(map[string]bool)
-			for i, part := range parts {
-				displayPath := app.out.ReplaceHomeTilde(part)
-
-				var annotations []string
-				if pathCheck {
-					if part == "" {
-						annotations = append(annotations, app.out.DiffSprintf("empty"))
-					} else {
-						if seen[part] {
-							annotations = append(annotations, app.out.DiffSprintf("duplicate"))
-						}
-						if _, err := os.Stat(part); os.IsNotExist(err) {
-							annotations = append(annotations, app.out.DiffSprintf("not found"))
-						}
-					}
-				}
+			if masked, ok := app.out.MaskValue(name, value); ok {
+				// Masked values are shown as a single entry that is not checked
+				listing.Paths = append(listing.Paths, pathVariable{Name: name, Entries: []pathEntry{{Path: masked}}})
+				continue
+			}
+			listing.Paths = append(listing.Paths, newPathVariable(name, value, pathCheck))
+		}
+		if structuredOutput() {
+			printStructured(listing)
+			return
+		}
 
+		for _, path := range listing.Paths {
+			output.Printf("%s\n", app.out.EnvNameSprintf("# %s", path.Name))
+			for i, entry := range path.Entries {
+				displayPath := app.out.ReplaceHomeTilde(entry.Path)
 				if i%2 == 1 {
 					displayPath = app.out.PathSprintf(displayPath)
 				}
-
-				if len(annotations) > 0 {
+				if len(entry.Issues) > 0 {
+					annotations := make([]string, len(entry.Issues))
+					for j, issue := range entry.Issues {
+						annotations[j] = app.out.DiffSprintf(issue)
+					}
 					output.Printf("%s  [%s]\n", displayPath, strings.Join(annotations, ", "))
 				} else {
 					output.Printf("%s\n", displayPath)
 				}
-				seen[part] = true
 			}
-			if pathCheck {
-				dupes := len(parts) - len(seen)
-				missing := 0
-				for part := range seen {
-					if part != "" {
-						if _, err := os.Stat(part); os.IsNotExist(err) {
-							missing++
-						}
+			if !pathCheck {
+				output.Printf("%s\n", app.out.GroupSprintf("# %s", entryCount(len(path.Entries))))
+			} else if path.Duplicates > 0 || path.Missing > 0 {
+				var issues []string
+				if path.Duplicates > 0 {
+					word := "duplicates"
+					if path.Duplicates == 1 {
+						word = "duplicate"
 					}
+					issues = append(issues, fmt.Sprintf("%d %s", path.Duplicates, word))
 				}
-				if dupes > 0 || missing > 0 {
-					var issues []string
-					if dupes > 0 {
-						word := "duplicates"
-						if dupes == 1 {
-							word = "duplicate"
-						}
-						issues = append(issues, fmt.Sprintf("%d %s", dupes, word))
-					}
-					if missing > 0 {
-						issues = append(issues, fmt.Sprintf("%d missing", missing))
-					}
-					output.Printf("%s %s\n", app.out.GroupSprintf("# %s —", entryCount(len(parts))), app.out.DiffSprintf(strings.Join(issues, ", ")))
-				} else {
-					output.Printf("%s %s\n", app.out.GroupSprintf("# %s —", entryCount(len(parts))), app.out.ProfileSprintf("all ok"))
+				if path.Missing > 0 {
+					issues = append(issues, fmt.Sprintf("%d missing", path.Missing))
 				}
+				output.Printf("%s %s\n", app.out.GroupSprintf("# %s —", entryCount(len(path.Entries))), app.out.DiffSprintf(strings.Join(issues, ", ")))
 			} else {
-				output.Printf("%s\n", app.out.GroupSprintf("# %s", entryCount(len(parts))))
+				output.Printf("%s %s\n", app.out.GroupSprintf("# %s —", entryCount(len(path.Entries))), app.out.ProfileSprintf("all ok"))
 			}
 		}
 	},
 }
 
+// pathListing is the structured output of the path command.
+type pathListing struct {
+	Paths []pathVariable `json:"paths"`
+}
+
+type pathVariable struct {
+	Name       string      `json:"name"`
+	Entries    []pathEntry `json:"entries"`
+	Duplicates int         `json:"duplicates"`
+	Missing    int         `json:"missing"`
+}
+
+type pathEntry struct {
+	Path   string   `json:"path"`
+	Issues []string `json:"issues,omitempty"`
+}
+
+// newPathVariable splits value into entries, checking each one for problems if check is set.
+func newPathVariable(name, value string, check bool) pathVariable {
+	parts := strings.Split(value, string(os.PathListSeparator))
+	result := pathVariable{Name: name, Entries: make([]pathEntry, 0, len(parts))}
+	seen := make(map[string]bool)
+	for _, part := range parts {
+		entry := pathEntry{Path: part}
+		if check {
+			if part == "" {
+				entry.Issues = append(entry.Issues, "empty")
+			} else {
+				if seen[part] {
+					entry.Issues = append(entry.Issues, "duplicate")
+				}
+				if _, err := os.Stat(part); os.IsNotExist(err) {
+					entry.Issues = append(entry.Issues, "not found")
+					if !seen[part] {
+						result.Missing++
+					}
+				}
+			}
+		}
+		if seen[part] {
+			result.Duplicates++
+		}
+		seen[part] = true
+		result.Entries = append(result.Entries, entry)
+	}
+	return result
+}
+
 func entryCount(n int) string {
 	if n == 1 {
 		return "1 entry"
@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"sort"
+	"strings"
+
 	"github.com/spf13/cobra"
 	"github.com/sverrirab/envirou/pkg/output"
 )
@@ -11,6 +14,20 @@ var profilesCmd = &cobra.Command{
 	Short:   "List profiles",
 	GroupID: "profiles",
 	Run: func(cmd *cobra.Command, args []string) {
+		names := app.profileNames
+		if showActiveProfilesOnly {
+			names = app.activeProfileNames
+		} else if showInactiveProfilesOnly {
+			names = app.inactiveProfileNames
+		}
+		if templateOutput() {
+			printTemplate(output.TemplateData{Vars: newTemplateVariables(profileVariables(names)), Profiles: newProfileStates(names)})
+			return
+		}
+		if structuredOutput() {
+			printStructured(profileListing{Profiles: newProfileStates(names), Subshells: append([]string{}, subshells(app.baseEnv)...)})
+			return
+		}
 		for _, profileName := range app.profileNames {
 			active := app.isActiveProfile[profileName]
 			if active && !showInactiveProfilesOnly {
@@ -20,9 +37,40 @@ var profilesCmd = &cobra.Command{
 			}
 		}
 		output.Printf("\n")
+		if levels := subshells(app.baseEnv); len(levels) > 0 {
+			for i, level := range levels {
+				levels[i] = app.out.ProfileSprintf(level)
+			}
+			output.Printf("Subshells: %s (exit to return)\n", strings.Join(levels, " > "))
+		}
 	},
 }
 
+// profileListing is the structured output of the profiles command.
+type profileListing struct {
+	Profiles  []output.ProfileState `json:"profiles"`
+	Subshells []string              `json:"subshells"`
+}
+
+// profileVariables returns the sorted names of the variables in the current environment set by the profiles.
+func profileVariables(profileNames []string) []string {
+	set := make(map[string]bool)
+	for _, profileName := range profileNames {
+		profile := app.configuration.Profiles[profileName]
+		for _, name := range profile.SortedNames(false) {
+			if _, found := app.baseEnv.Get(name); found {
+				set[app.baseEnv.GetCorrectCase(name, false)] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 var (
 	showActiveProfilesOnly   bool = false
 	showInactiveProfilesOnly bool = false
@@ -30,6 +78,7 @@ var (
 
 func init() {
 	addCommand(profilesCmd)
+	addFormatFlag(profilesCmd)
 
 	profilesCmd.Flags().BoolVarP(&showActiveProfilesOnly, "active", "a", showActiveProfilesOnly, "Show active profiles only")
 	profilesCmd.Flags().BoolVarP(&showInactiveProfilesOnly, "inactive", "i", showInactiveProfilesOnly, "Show inactive profiles only")
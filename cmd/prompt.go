@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"github.com/sverrirab/envirou/pkg/config"
+	"github.com/sverrirab/envirou/pkg/data"
+	"github.com/sverrirab/envirou/pkg/output"
+)
+
+var promptNoCache bool
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Print active profiles for use in a shell prompt",
+	Long: `Prints a compact summary of the active profiles and a marker if the environment
+changed since the last snapshot, e.g. [dev,aws*]. Nothing is printed if no profile
+is active and nothing changed. The format is set in the [prompt] section of the
+config file.
+
+This runs on every prompt so the state needed is cached in prompt.cache next to
+the config file, it is rebuilt when the config file or snapshot changes.
+Use "envirou bootstrap bash|zsh --prompt" or "envirou bootstrap starship" to install.`,
+	GroupID: "profiles",
+	Args:    cobra.NoArgs,
+	// Replaces the root initialization, the config file is only read if the cache is stale.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		if cfgFile == "" {
+			cfgFile = config.GetDefaultConfigFilePath()
+		}
+		//goland:noinspection ALL
+		app.caseInsensitive = runtime.GOOS == "windows"
+		app.shellCommands = nil
+
+		cache, found := config.LoadPromptCache(cfgFile)
+		if found && !promptNoCache {
+			if verbose {
+				output.Printf("Using cached prompt state: %s\n", config.GetPromptCacheFilePath())
+			}
+		} else {
+			configuration, err := config.ReadConfiguration(cfgFile, app.caseInsensitive)
+			if err != nil {
+				output.Printf("Failed to read config file: %v\n", err)
+				os.Exit(3)
+			}
+			snapshot, _ := config.LoadSnapshot(app.caseInsensitive)
+			cache = config.NewPromptCache(cfgFile, configuration, snapshot)
+			if err := config.SavePromptCache(cache); err != nil && verbose {
+				output.Printf("Failed to save prompt cache: %v\n", err)
+			}
+		}
+
+		env := data.NewProfile(app.caseInsensitive)
+		env.MergeStrings(os.Environ())
+		if prompt := cache.Sprint(env, app.caseInsensitive); prompt != "" {
+			fmt.Println(prompt)
+		}
+	},
+}
+
+func init() {
+	promptCmd.Flags().BoolVar(&promptNoCache, "no-cache", false, "Read the config file even if the cache is up to date")
+	addCommand(promptCmd)
+}
@@ -9,7 +9,9 @@ import (
 	"os"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/spf13/cobra"
 )
@@ -17,10 +19,7 @@ import (
 func displayGroup(out *output.Output, name string, envs data.Envs, profile *data.Profile, sh *shell.Shell) bool {
 	if len(envs) > 0 {
 		out.PrintGroup(name)
-		for _, env := range envs {
-			value, _ := profile.Get(env)
-			out.PrintEnv(sh, env, value)
-		}
+		out.PrintEnvs(sh, envs, profile)
 		return true
 	}
 	return false
@@ -36,19 +35,43 @@ Using without any command will display the current environment in a shortened fo
 The next step is to use "set" to modify the current environment (this requires "ev"
 shell function to be installed)`,
 	Run: func(cmd *cobra.Command, args []string) {
-		matches, remaining := app.configuration.Groups.MatchAll(app.baseEnv.SortedNames(false), app.caseInsensitive)
+		groups := &app.configuration.Groups
+		matches, remaining := groups.MatchAll(app.baseEnv.SortedNames(false), app.caseInsensitive)
+		if templateOutput() {
+			names := app.baseEnv.SortedNames(false)
+			if len(actionShowGroups) > 0 {
+				names = make([]string, 0)
+				for _, groupName := range actionShowGroups {
+					names = append(names, groups.SortEnvs(groupName, matches[groupName], app.baseEnv, app.caseInsensitive)...)
+				}
+			}
+			printTemplate(output.TemplateData{Vars: newTemplateVariables(names), Profiles: newProfileStates(app.profileNames)})
+			return
+		}
+		if structuredOutput() {
+			printStructured(newEnvironmentListing(matches, remaining))
+			return
+		}
 		if !showAllGroups && len(actionShowGroups) > 0 {
 			for _, actionShowGroup := range actionShowGroups {
-				if !displayGroup(app.out, actionShowGroup, matches[actionShowGroup], app.baseEnv, app.sh) {
+				envs := groups.SortEnvs(actionShowGroup, matches[actionShowGroup], app.baseEnv, app.caseInsensitive)
+				if !displayGroup(app.out, actionShowGroup, envs, app.baseEnv, app.sh) {
 					output.Printf(app.out.GroupSprintf("# %s (group empty, use -a to show all)\n", actionShowGroup))
 				}
 			}
 		} else {
 			notDisplayed := make([]string, 0)
-			for _, groupName := range matches.GetAllNames() {
+			for _, groupName := range groups.GetDisplayNames() {
+				envs := matches[groupName]
 				hideGroup := !showAllGroups && strings.HasPrefix(groupName, ".")
-				if hideGroup || !displayGroup(app.out, groupName, matches[groupName], app.baseEnv, app.sh) {
+				if len(envs) == 0 {
+					continue
+				} else if hideGroup {
 					notDisplayed = append(notDisplayed, groupName)
+				} else if !showAllGroups && groups.GetOptions(groupName).Collapsed {
+					output.Printf(app.out.GroupSprintf("# %s (%s collapsed, use -g %s to show)\n", groupName, variableCount(len(envs)), groupName))
+				} else {
+					displayGroup(app.out, groupName, groups.SortEnvs(groupName, envs, app.baseEnv, app.caseInsensitive), app.baseEnv, app.sh)
 				}
 			}
 			displayGroup(app.out, "(no group)", remaining, app.baseEnv, app.sh)
@@ -59,24 +82,197 @@ shell function to be installed)`,
 		}
 		app.out.PrintProfileList(app.profileNames, app.activeProfileNames)
 	},
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if isCompletionCommand(cmd) {
+			// Candidates and scripts are read from stdout, the completion functions read
+			// the configuration once the flags are parsed
+			setCommandOutput(cmd.Root(), os.Stdout)
+			app.shellCommands = nil
+			return
+		}
+		setCommandOutput(cmd.Root(), os.Stderr)
+		initConfig()
+	},
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
 		if len(app.shellCommands) > 0 {
 			commands := app.sh.RunCommands(app.shellCommands)
 			if verbose || dryRun {
-				output.Printf("Shell commands to execute:\n>\n> %s>\n", commands)
+				output.Printf("Shell commands to execute:\n>\n> %s>\n", app.sh.RunCommands(displayCommands()))
 			}
 			if !dryRun {
-				fmt.Print(commands)
+				writeShellCommands(commands)
 			}
 		}
+		closeCommandChannel()
 	},
 }
 
+// environmentListing is the structured output of the root command.
+type environmentListing struct {
+	Groups    []output.Group        `json:"groups"`
+	Ungrouped []output.Variable     `json:"ungrouped"`
+	Profiles  []output.ProfileState `json:"profiles"`
+}
+
+func newEnvironmentListing(matches data.GroupNameToEnvs, remaining data.Envs) environmentListing {
+	groups := &app.configuration.Groups
+	names := groups.GetDisplayNames()
+	if len(actionShowGroups) > 0 {
+		names = actionShowGroups
+	}
+	listing := environmentListing{
+		Groups:    make([]output.Group, 0, len(names)),
+		Ungrouped: newVariables(remaining),
+		Profiles:  newProfileStates(app.profileNames),
+	}
+	for _, name := range names {
+		envs := matches[name]
+		if len(envs) == 0 && len(actionShowGroups) == 0 {
+			continue
+		}
+		listing.Groups = append(listing.Groups, output.Group{
+			Name:      name,
+			Hidden:    strings.HasPrefix(name, "."),
+			Collapsed: groups.GetOptions(name).Collapsed,
+			Variables: newVariables(groups.SortEnvs(name, envs, app.baseEnv, app.caseInsensitive)),
+		})
+	}
+	return listing
+}
+
+func newVariables(names []string) []output.Variable {
+	variables := make([]output.Variable, 0, len(names))
+	for _, name := range names {
+		value, _ := app.baseEnv.Get(name)
+		variables = append(variables, app.out.NewVariable(name, value))
+	}
+	return variables
+}
+
+func newProfileStates(names []string) []output.ProfileState {
+	profiles := make([]output.ProfileState, 0, len(names))
+	for _, name := range names {
+		profiles = append(profiles, output.ProfileState{Name: name, Active: app.isActiveProfile[name]})
+	}
+	return profiles
+}
+
+// structuredOutput returns true if --output selected json or yaml.
+func structuredOutput() bool {
+	return outputFormat != output.FormatText
+}
+
+// printStructured writes v to stdout in the format selected with --output.
+func printStructured(v interface{}) {
+	if err := output.Encode(os.Stdout, outputFormat, v); err != nil {
+		output.Printf("Failed to write %s output: %v\n", outputFormat, err)
+	}
+}
+
+// addEnvironmentCommands queues the shell commands changing the current environment to newEnv.
+// Nothing is queued if a variable name could not be used safely in the shell (a name like
+// X;rm -rf ~ from a .env file would otherwise run as code).
+func addEnvironmentCommands(newEnv *data.Profile) error {
+	added, removed := app.baseEnv.Diff(newEnv)
+	if invalid := app.sh.InvalidNames(append(added, removed...)); len(invalid) > 0 {
+		quoted := make([]string, len(invalid))
+		for i, name := range invalid {
+			quoted[i] = strconv.Quote(name)
+		}
+		return fmt.Errorf("invalid variable names: %s", strings.Join(quoted, ", "))
+	}
+	commands := app.sh.GetCommands(app.baseEnv, newEnv)
+	masked := app.sh.GetDisplayCommands(app.baseEnv, newEnv, app.out.MaskValue)
+	for i := range commands {
+		if commands[i] != masked[i] {
+			app.maskedCommands[commands[i]] = masked[i]
+		}
+	}
+	app.shellCommands = append(app.shellCommands, commands...)
+	return nil
+}
+
+// applyEnvironment queues the commands changing the current environment to newEnv or
+// exits if that is not possible.
+func applyEnvironment(newEnv *data.Profile) {
+	if err := addEnvironmentCommands(newEnv); err != nil {
+		output.Printf("Not changing the environment, %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// displayCommands returns the shell commands with secret values masked for display.
+func displayCommands() []string {
+	commands := make([]string, len(app.shellCommands))
+	for i, command := range app.shellCommands {
+		if masked, found := app.maskedCommands[command]; found {
+			command = masked
+		}
+		commands[i] = command
+	}
+	return commands
+}
+
+// templateOutput returns true if a --format template was given.
+func templateOutput() bool {
+	return app.template != nil
+}
+
+// printTemplate writes the result of the --format template to stdout.
+func printTemplate(data output.TemplateData) {
+	if err := output.ExecuteTemplate(os.Stdout, app.template, data); err != nil {
+		output.Printf("Failed to execute format template: %v\n", err)
+		os.Exit(3)
+	}
+}
+
+// newTemplateVariables builds the --format data for the named variables of the current environment.
+func newTemplateVariables(names []string) []output.TemplateVariable {
+	groups := &app.configuration.Groups
+	matches, _ := groups.MatchAll(names, app.caseInsensitive)
+	variableGroups := make(map[string][]string)
+	for _, groupName := range groups.GetDisplayNames() {
+		for _, name := range matches[groupName] {
+			variableGroups[name] = append(variableGroups[name], groupName)
+		}
+	}
+	variables := make([]output.TemplateVariable, 0, len(names))
+	for _, name := range names {
+		value, _ := app.baseEnv.Get(name)
+		v := app.out.NewTemplateVariable(name, value)
+		v.Groups = variableGroups[name]
+		if len(v.Groups) > 0 {
+			v.Group = v.Groups[0]
+		}
+		v.Profiles = settingProfiles(name)
+		variables = append(variables, v)
+	}
+	return variables
+}
+
+// settingProfiles returns the names of the profiles that set or unset the variable.
+func settingProfiles(name string) []string {
+	names := make([]string, 0)
+	for _, profileName := range app.profileNames {
+		profile := app.configuration.Profiles[profileName]
+		if _, found := profile.Get(name); found || profile.GetNil(name) {
+			names = append(names, profileName)
+		}
+	}
+	return names
+}
+
+// addFormatFlag adds the --format flag to commands supporting template output.
+func addFormatFlag(command *cobra.Command) {
+	command.Flags().StringVar(&outputTemplate, "format", "", "Format output using a Go template, e.g. '{{range .Vars}}{{.Name}}\\n{{end}}'")
+}
+
 // appState holds runtime state initialized during startup.
 type appState struct {
 	caseInsensitive      bool
 	configuration        *config.Configuration
 	sh                   *shell.Shell
+	shellName            string // Name of the selected shell, see selectShell
 	out                  *output.Output
 	baseEnv              *data.Profile
 	snapshot             *data.Profile
@@ -85,6 +281,10 @@ type appState struct {
 	inactiveProfileNames []string
 	isActiveProfile      map[string]bool
 	shellCommands        []string
+	maskedCommands       map[string]string // Display version of shell commands containing secrets
+	commandChannel       *os.File          // Where shell commands are written if not to stdout
+	exitCode             int
+	template             *template.Template
 }
 
 var (
@@ -93,34 +293,135 @@ var (
 	// Initial configuration
 	cfgFile             string
 	bashBootstrap       string
+	bashPrompt          string
+	zshPrompt           string
+	fishBootstrap       string
+	nushellBootstrap    string
+	elvishBootstrap     string
+	tcshBootstrap       string
 	powershellBootstrap string
 	powershellPrompt    string
 	batBootstrap        string
+	starshipModule      string
 
 	// Global flags
 	verbose            bool
 	noColor            bool
 	displayUnformatted bool
+	shellName          string
 	outputPowerShell   bool
+	outputFish         bool
+	outputNushell      bool
+	outputElvish       bool
+	outputTcsh         bool
 	dryRun             bool
+	outputFormat       string
+	reveal             bool
+	outputTemplate     string
+	wide               bool
 
 	// Used by root command
 	showAllGroups    bool
 	actionShowGroups []string
 )
 
+// Scripts are the embedded shell integration scripts output by bootstrap.
+type Scripts struct {
+	Bash             string
+	BashPrompt       string
+	ZshPrompt        string
+	Fish             string
+	Nushell          string
+	Elvish           string
+	Tcsh             string
+	PowerShell       string
+	PowerShellPrompt string
+	Bat              string
+	Starship         string
+}
+
+// setScripts makes the bootstrap scripts available to the bootstrap command.
+func setScripts(scripts Scripts) {
+	bashBootstrap = scripts.Bash
+	bashPrompt = scripts.BashPrompt
+	zshPrompt = scripts.ZshPrompt
+	fishBootstrap = scripts.Fish
+	nushellBootstrap = scripts.Nushell
+	elvishBootstrap = scripts.Elvish
+	tcshBootstrap = scripts.Tcsh
+	powershellBootstrap = scripts.PowerShell
+	powershellPrompt = scripts.PowerShellPrompt
+	batBootstrap = scripts.Bat
+	starshipModule = scripts.Starship
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
-func Execute(bash, powershell, psPrompt, bat string) {
-	bashBootstrap = bash
-	powershellBootstrap = powershell
-	powershellPrompt = psPrompt
-	batBootstrap = bat
+func Execute(scripts Scripts) {
+	setScripts(scripts)
 
+	// The completion command writes to the output it was created with, the scripts go to stdout
+	if len(os.Args) > 1 && os.Args[1] == "completion" {
+		rootCmd.SetOut(os.Stdout)
+	}
 	err := rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
 	}
+	os.Exit(app.exitCode)
+}
+
+const (
+	// commandFDVariable names a file descriptor the ev wrapper reads shell commands from.
+	commandFDVariable = "ENVIROU_CMD_FD"
+	// commandFileVariable names a file the ev wrapper runs after envirou exits.
+	commandFileVariable = "ENVIROU_CMD_FILE"
+)
+
+// openCommandChannel opens the file descriptor or file given by the ev wrapper for the shell
+// commands, nil if there is none (older wrappers evaluate stdout). The variables are removed
+// so they are not part of the environment envirou shows or changes.
+func openCommandChannel() *os.File {
+	fd, file := os.Getenv(commandFDVariable), os.Getenv(commandFileVariable)
+	_ = os.Unsetenv(commandFDVariable)
+	_ = os.Unsetenv(commandFileVariable)
+	if fd != "" {
+		n, err := strconv.Atoi(fd)
+		if err != nil || n < 3 {
+			output.Printf("Invalid %s=%s (use a file descriptor above 2)\n", commandFDVariable, fd)
+			os.Exit(3)
+		}
+		return os.NewFile(uintptr(n), commandFDVariable)
+	}
+	if file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			output.Printf("Failed to open %s: %v\n", commandFileVariable, err)
+			os.Exit(3)
+		}
+		return f
+	}
+	return nil
+}
+
+// writeShellCommands writes the commands for the ev wrapper to evaluate.
+func writeShellCommands(commands string) {
+	if app.commandChannel == nil {
+		fmt.Print(commands)
+		return
+	}
+	if _, err := app.commandChannel.WriteString(commands); err != nil {
+		output.Printf("Failed to write shell commands: %v\n", err)
+		app.exitCode = 3
+	}
+}
+
+// closeCommandChannel closes the file or file descriptor opened by openCommandChannel.
+func closeCommandChannel() {
+	if app.commandChannel != nil {
+		_ = app.commandChannel.Close()
+		app.commandChannel = nil
+	}
 }
 
 func addCommand(command *cobra.Command) {
@@ -131,34 +432,104 @@ func addCommand(command *cobra.Command) {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
 	rootCmd.SetOut(os.Stderr)
 
 	// Flags for root command
 	rootCmd.Flags().BoolVarP(&showAllGroups, "all", "a", showAllGroups, "List all groups")
 	rootCmd.Flags().StringArrayVarP(&actionShowGroups, "group", "g", nil, "Show individual group")
 	rootCmd.MarkFlagsMutuallyExclusive("all", "group")
+	_ = rootCmd.RegisterFlagCompletionFunc("group", completeGroupFlag)
+	addFormatFlag(rootCmd)
 
 	// Flags for all commands
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.envirou/config.ini)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", verbose, "Increase output verbosity")
 	rootCmd.PersistentFlags().BoolVarP(&displayUnformatted, "unformatted", "u", displayUnformatted, "Display unformatted env variables")
 	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", noColor, "Disable colored output")
+	rootCmd.PersistentFlags().StringVar(&shellName, "shell", "", "Shell to output commands for: "+strings.Join(shell.Names(), ", ")+" (default is detected)")
+	_ = rootCmd.RegisterFlagCompletionFunc("shell", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return shell.Names(), cobra.ShellCompDirectiveNoFileComp
+	})
+	// Replaced by --shell, still accepted for ev wrappers installed by older versions
 	rootCmd.PersistentFlags().BoolVar(&outputPowerShell, "output-powershell", outputPowerShell, "Enable PowerShell output")
+	rootCmd.PersistentFlags().BoolVar(&outputFish, "output-fish", outputFish, "Enable fish output")
+	rootCmd.PersistentFlags().BoolVar(&outputNushell, "output-nushell", outputNushell, "Enable nushell output")
+	rootCmd.PersistentFlags().BoolVar(&outputElvish, "output-elvish", outputElvish, "Enable elvish output")
+	rootCmd.PersistentFlags().BoolVar(&outputTcsh, "output-tcsh", outputTcsh, "Enable tcsh/csh output")
+	for _, name := range []string{"output-powershell", "output-fish", "output-nushell", "output-elvish", "output-tcsh"} {
+		_ = rootCmd.PersistentFlags().MarkHidden(name)
+	}
+	rootCmd.MarkFlagsMutuallyExclusive("shell", "output-powershell", "output-fish", "output-nushell", "output-elvish", "output-tcsh")
 	rootCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "n", dryRun, "Only display what would be changed")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", output.FormatText, "Output format: text, json or yaml")
+	rootCmd.PersistentFlags().BoolVar(&reveal, "reveal", false, "Show values of secret variables")
+	rootCmd.PersistentFlags().BoolVarP(&wide, "wide", "w", false, "Do not truncate long values to fit the terminal")
 
 	rootCmd.AddGroup(&cobra.Group{ID: "profiles", Title: "Profile commands"})
 	rootCmd.AddGroup(&cobra.Group{ID: "groups", Title: "Group commands"})
 	rootCmd.AddGroup(&cobra.Group{ID: "configuration", Title: "Configuration commands"})
 }
 
+// detectShell is replaced in tests so the output does not depend on the shell running them.
+var detectShell = shell.Detect
+
+// selectShell returns the shell given with --shell (or one of the older --output-* flags),
+// otherwise the detected shell.
+func selectShell() (*shell.Shell, string) {
+	name, source := shellName, "--shell"
+	for _, selected := range []struct {
+		enabled bool
+		name    string
+	}{{outputPowerShell, "powershell"}, {outputFish, "fish"}, {outputNushell, "nushell"}, {outputElvish, "elvish"}, {outputTcsh, "tcsh"}} {
+		if selected.enabled {
+			name, source = selected.name, "--output-"+selected.name
+		}
+	}
+	if name == "" {
+		name, source = detectShell()
+	}
+	sh, found := shell.Lookup(name)
+	if !found {
+		output.Printf("Unknown shell %s (use one of %s)\n", name, strings.Join(shell.Names(), ", "))
+		os.Exit(3)
+	}
+	if verbose {
+		output.Printf("Shell: %s (from %s)\n", name, source)
+	}
+	return sh, name
+}
+
 func initConfig() {
+	// With a separate channel for the shell commands, stdout is free for the user
+	closeCommandChannel()
+	app.commandChannel = openCommandChannel()
+	app.exitCode = 0
+	output.UseStdout(app.commandChannel != nil)
+
 	if cfgFile == "" {
 		cfgFile = config.GetDefaultConfigFilePath()
 	}
 	//goland:noinspection ALL
 	app.caseInsensitive = runtime.GOOS == "windows"
 
+	if !output.IsValidFormat(outputFormat) {
+		output.Printf("Invalid output format %s (use text, json or yaml)\n", outputFormat)
+		os.Exit(3)
+	}
+	app.template = nil
+	if outputTemplate != "" {
+		if structuredOutput() {
+			output.Printf("The --format and --output flags cannot be combined\n")
+			os.Exit(3)
+		}
+		var err error
+		app.template, err = output.NewTemplate(outputTemplate)
+		if err != nil {
+			output.Printf("Invalid format template: %v\n", err)
+			os.Exit(3)
+		}
+	}
+
 	var err error
 	app.configuration, err = config.ReadConfiguration(cfgFile, app.caseInsensitive)
 	if err != nil {
@@ -169,19 +540,20 @@ func initConfig() {
 		output.Printf("Read config file: %s\n", cfgFile)
 	}
 
-	// Display modifiers
-	output.NoColor(noColor)
+	// Display modifiers, no color when piping output to other tools
+	output.NoColor(noColor || output.Writer() == os.Stdout && output.TerminalWidth(os.Stdout) == 0)
 	replacePathTilde := ""
 	if app.configuration.SettingsPathTilde {
 		replacePathTilde = os.Getenv("HOME")
 	}
-	if app.caseInsensitive {
-		app.sh = shell.NewShell(outputPowerShell, !outputPowerShell)
-	} else {
-		app.sh = shell.NewShell(false, false)
-	}
+	app.sh, app.shellName = selectShell()
 
-	app.out = output.NewOutput(replacePathTilde, app.configuration.SettingsPath, app.configuration.SettingsPassword, displayUnformatted, app.caseInsensitive, app.configuration.FormatGroup, app.configuration.FormatProfile, app.configuration.FormatEnvName, app.configuration.FormatPath, app.configuration.FormatDiff)
+	app.out = output.NewOutput(replacePathTilde, app.configuration.SettingsPathMatcher, app.configuration.SettingsPasswordMatcher, displayUnformatted, app.configuration.FormatGroup, app.configuration.FormatProfile, app.configuration.FormatEnvName, app.configuration.FormatPath, app.configuration.FormatDiff)
+	app.out.SetReveal(reveal)
+	app.out.SetMasking(app.configuration.SettingsSecretDetection, app.configuration.SettingsMaskStyle)
+	if app.configuration.SettingsTable {
+		app.out.SetTable(output.TerminalWidth(output.Writer()), wide)
+	}
 
 	app.baseEnv = data.NewProfile(app.caseInsensitive)
 	app.baseEnv.MergeStrings(os.Environ())
@@ -220,4 +592,5 @@ func initConfig() {
 	sort.Strings(app.inactiveProfileNames)
 
 	app.shellCommands = make([]string, 0)
+	app.maskedCommands = make(map[string]string)
 }
@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"sort"
 	"strings"
 
 	"github.com/sverrirab/envirou/pkg/config"
 	"github.com/sverrirab/envirou/pkg/data"
 	"github.com/sverrirab/envirou/pkg/output"
+	"github.com/sverrirab/envirou/pkg/tui"
 
 	"github.com/spf13/cobra"
 )
@@ -17,11 +21,22 @@ var setCmd = &cobra.Command{
 	Short:   "Update current environment using profiles",
 	Long: `Each profile will be merged with your current environment
 
+Without arguments (or with --interactive) a fuzzy picker is shown: type to filter,
+Tab to select several profiles and Enter to set them. Arguments given with
+--interactive are used as the initial filter.
+
 To change profiles edit the config file (see "config" command)`,
-	// ValidArgs: []string{"one", "two", "three"},
-	GroupID: "profiles",
-	Args:    cobra.MatchAll(cobra.MinimumNArgs(1)),
+	GroupID:           "profiles",
+	ValidArgsFunction: completeProfiles,
+	Args:              cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 || setInteractive {
+			picked, ok := pickProfiles(strings.Join(args, " "))
+			if !ok {
+				return
+			}
+			args = picked
+		}
 		newEnv := app.baseEnv.Clone()
 		var notFound []string
 		var alreadyActive []string
@@ -52,11 +67,61 @@ To change profiles edit the config file (see "config" command)`,
 		}
 		if len(notFound) > 0 {
 			output.Printf("Warning: profiles not found: %s\n", strings.Join(notFound, ", "))
+			// The profiles found are still applied, but ev set missing && ... fails
+			app.exitCode = 1
 		}
-		app.shellCommands = append(app.shellCommands, app.sh.GetCommands(app.baseEnv, newEnv)...)
+		applyEnvironment(newEnv)
 	},
 }
 
+// pickProfiles lets the user choose profiles with the inline picker.
+func pickProfiles(query string) ([]string, bool) {
+	tty, err := openTerminal()
+	if err != nil {
+		output.Printf("No profile given and no terminal to pick one: %v\n", err)
+		os.Exit(1)
+	}
+	picked, ok, err := tui.RunPicker(tty, tui.PickerOptions{
+		Prompt:  "set> ",
+		Items:   app.profileNames,
+		Query:   query,
+		Active:  app.isActiveProfile,
+		Preview: previewProfiles,
+	})
+	_ = tty.Close()
+	if err != nil {
+		output.Printf("Failed to pick profiles: %v\n", err)
+		os.Exit(1)
+	}
+	return picked, ok
+}
+
+// previewProfiles describes the changes setting the profiles would make, with secrets masked.
+func previewProfiles(names []string) []string {
+	newEnv := app.baseEnv.Clone()
+	for _, name := range names {
+		if profile, found := app.configuration.Profiles.FindProfile(name); found {
+			newEnv.Merge(profile)
+		}
+	}
+	changed, removed := app.baseEnv.Diff(newEnv)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	lines := make([]string, 0, len(changed)+len(removed))
+	for _, name := range changed {
+		value, _ := newEnv.Get(name)
+		value, _ = app.out.MaskValue(name, value)
+		lines = append(lines, fmt.Sprintf("%s=%s", name, value))
+	}
+	for _, name := range removed {
+		lines = append(lines, "unset "+name)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No changes (already active)")
+	}
+	return lines
+}
+
 func findProfile(out *output.Output, cfg *config.Configuration, name string) (*data.Profile, bool) {
 	profile, found := cfg.Profiles.FindProfile(name)
 	if !found {
@@ -65,6 +130,9 @@ func findProfile(out *output.Output, cfg *config.Configuration, name string) (*d
 	return profile, found
 }
 
+var setInteractive bool
+
 func init() {
+	setCmd.Flags().BoolVarP(&setInteractive, "interactive", "i", false, "Pick profiles with a fuzzy picker")
 	addCommand(setCmd)
 }
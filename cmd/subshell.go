@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sverrirab/envirou/pkg/data"
+	"github.com/sverrirab/envirou/pkg/output"
+)
+
+const (
+	// subshellVariable is set in subshells started by the shell command, to the profiles of
+	// each nested subshell, outermost first.
+	subshellVariable = "ENVIROU_SUBSHELL"
+	// subshellSeparator separates the nested subshells in subshellVariable.
+	subshellSeparator = ">"
+)
+
+var subshellCmd = &cobra.Command{
+	Use:   "shell PROFILE [PROFILE]...",
+	Short: "Start a subshell with profiles applied",
+	Long: `Start a new shell with the profiles applied, exit it to return to the unchanged environment.
+
+The shell is the one given with --shell, otherwise $SHELL. ENVIROU_SUBSHELL is set to the
+profiles so the prompt can show them, nested subshells are added with > (dev>aws-prod).
+The active subshells are listed by the profiles command.`,
+	GroupID:           "profiles",
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeProfiles,
+	Run: func(cmd *cobra.Command, args []string) {
+		newEnv, ok := newProfileEnvironment(app.baseEnv, args, nil)
+		if !ok {
+			os.Exit(1)
+		}
+		newEnv.Set(subshellVariable, strings.Join(append(subshells(app.baseEnv), strings.Join(args, ",")), subshellSeparator))
+		program := subshellProgram()
+		if verbose {
+			output.Printf("Starting %s, exit it to return\n", program)
+		}
+		app.exitCode = runCommand([]string{program}, newEnv)
+	},
+}
+
+// subshells returns the profiles of the nested subshells of env, outermost first.
+func subshells(env *data.Profile) []string {
+	value, _ := env.Get(subshellVariable)
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, subshellSeparator)
+}
+
+// subshellProgram returns the shell to start: the one selected with --shell (or an older
+// --output flag) if given, otherwise $SHELL, falling back to the detected shell.
+func subshellProgram() string {
+	name := ""
+	if shellName != "" || outputPowerShell || outputFish || outputNushell || outputElvish || outputTcsh {
+		name = app.shellName
+	} else if program := os.Getenv("SHELL"); program != "" {
+		return program
+	} else if program := os.Getenv("COMSPEC"); program != "" && runtime.GOOS == "windows" {
+		return program
+	} else {
+		name, _ = detectShell()
+	}
+	switch name {
+	case "bat":
+		return "cmd"
+	case "nushell":
+		return "nu"
+	case "powershell":
+		if runtime.GOOS != "windows" {
+			// PowerShell 7 is the only one outside Windows
+			return "pwsh"
+		}
+	}
+	return name
+}
+
+func init() {
+	addCommand(subshellCmd)
+}
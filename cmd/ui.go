@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sverrirab/envirou/pkg/output"
+	"github.com/sverrirab/envirou/pkg/tui"
+)
+
+// terminal is the terminal the full-screen commands draw on.
+type terminal interface {
+	tui.Terminal
+	io.Closer
+}
+
+// openTerminal puts the terminal in raw mode, drawing on stderr since stdout is evaluated
+// by the shell. Tests replace it with a scripted terminal.
+var openTerminal = func() (terminal, error) {
+	return tui.OpenTTY(os.Stdin, os.Stderr)
+}
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Browse the environment and profiles in a full-screen interface",
+	Long: `Browse groups and variables, filtering by typing. Press Enter to reveal a masked value
+and Ctrl-T to show hidden groups. Press Tab to switch to the profiles, where Space
+activates or deactivates the selected profile and shows a preview of its changes.
+
+Profile changes are applied to the current shell on exit (Esc), or discarded with Ctrl-C.`,
+	GroupID: "profiles",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		tty, err := openTerminal()
+		if err != nil {
+			output.Printf("The ui command requires a terminal: %v\n", err)
+			os.Exit(1)
+		}
+		newEnv, apply, err := tui.Run(tty, tui.Options{
+			Env:             app.baseEnv,
+			Groups:          &app.configuration.Groups,
+			Profiles:        app.configuration.Profiles,
+			Out:             app.out,
+			CaseInsensitive: app.caseInsensitive,
+		})
+		_ = tty.Close()
+		if err != nil {
+			output.Printf("Failed to run ui: %v\n", err)
+			os.Exit(1)
+		}
+		if apply {
+			applyEnvironment(newEnv)
+		}
+	},
+}
+
+func init() {
+	addCommand(uiCmd)
+}
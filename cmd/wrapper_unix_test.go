@@ -0,0 +1,87 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestSetCommandFD(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// The command channel closes the descriptor it is given, so hand it a copy
+	fd, err := syscall.Dup(int(w.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	t.Setenv("ENVIROU_CMD_FD", strconv.Itoa(fd))
+	out, errOut := executeCommandWithStderr(t, "set", "dev")
+	commands, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(commands) != "export TEST_ENV=development\n" {
+		t.Errorf("Expected shell commands on the pipe, got: %q", commands)
+	}
+	if !strings.Contains(out, "Profile dev enabled") || strings.Contains(out, "export") || errOut != "" {
+		t.Errorf("Expected user output on stdout, got: %q and stderr: %q", out, errOut)
+	}
+	if _, found := os.LookupEnv("ENVIROU_CMD_FD"); found {
+		t.Error("Expected ENVIROU_CMD_FD to be removed from the environment")
+	}
+	if app.exitCode != 0 {
+		t.Errorf("Expected exit code 0, got: %d", app.exitCode)
+	}
+}
+
+func TestBashWrapper(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+	dir := t.TempDir()
+	// The wrapper runs envirou from PATH, which is this test binary running Execute (see TestMain)
+	if err := os.Symlink(os.Args[0], filepath.Join(dir, "envirou")); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "config.ini")
+	if err := os.WriteFile(config, []byte(testConfigForCmd), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	script := `source ../bash/ev.sh
+ev --config "$CONFIG" set dev; echo "status=$? TEST_ENV=$TEST_ENV"
+ev --config "$CONFIG" set missing; echo "status=$? TEST_ENV=$TEST_ENV"
+echo "ENVIROU_CMD_FD=${ENVIROU_CMD_FD-unset}"
+`
+	command := exec.Command(bash, "--norc", "--noprofile", "-c", script)
+	command.Env = append(os.Environ(), "ENVIROU_TEST_MAIN=1", "PATH="+dir+string(os.PathListSeparator)+os.Getenv("PATH"), "CONFIG="+config, "TEST_ENV=unchanged")
+	out, err := command.CombinedOutput()
+	if err != nil {
+		t.Fatalf("bash failed: %v\n%s", err, out)
+	}
+	for _, expected := range []string{
+		"Profile dev enabled",
+		"status=0 TEST_ENV=development\n",
+		"Profile missing not found",
+		"status=1 TEST_ENV=development\n",
+		"ENVIROU_CMD_FD=unset\n",
+	} {
+		if !strings.Contains(string(out), expected) {
+			t.Errorf("Expected %q in the output of the wrapper, got: %s", expected, out)
+		}
+	}
+	if strings.Contains(string(out), "export") {
+		t.Errorf("Expected the shell commands to be evaluated, not shown, got: %s", out)
+	}
+}
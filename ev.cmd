@@ -1 +1,7 @@
-@FOR /F "tokens=* USEBACKQ" %%g IN (`envirou %*`) do @%%g
\ No newline at end of file
+@set "ENVIROU_CMD_FILE=%TEMP%\envirou-%RANDOM%%RANDOM%.cmd"
+@envirou --shell bat %*
+@set ENVIROU_STATUS=%ERRORLEVEL%
+@if exist "%ENVIROU_CMD_FILE%" call "%ENVIROU_CMD_FILE%"
+@if exist "%ENVIROU_CMD_FILE%" del "%ENVIROU_CMD_FILE%"
+@set ENVIROU_CMD_FILE=
+@(set ENVIROU_STATUS=& exit /b %ENVIROU_STATUS%)
@@ -0,0 +1,10 @@
+#!/usr/bin/env fish
+function ev
+  # envirou writes the shell commands to a file and keeps stdout for its own output
+  set -l envirou_commands (mktemp)
+  env ENVIROU_CMD_FILE=$envirou_commands envirou --shell fish $argv
+  set -l envirou_status $status
+  source $envirou_commands
+  rm -f $envirou_commands
+  return $envirou_status
+end
@@ -10,6 +10,24 @@ import (
 //go:embed bash/ev.sh
 var embeddedBootstrapBash string
 
+//go:embed bash/prompt.bash
+var embeddedPromptBash string
+
+//go:embed bash/prompt.zsh
+var embeddedPromptZsh string
+
+//go:embed fish/ev.fish
+var embeddedBootstrapFish string
+
+//go:embed nushell/ev.nu
+var embeddedBootstrapNushell string
+
+//go:embed elvish/ev.elv
+var embeddedBootstrapElvish string
+
+//go:embed tcsh/ev.csh
+var embeddedBootstrapTcsh string
+
 //go:embed powershell/ev.ps1
 var embeddedBootstrapPowerShell string
 
@@ -19,6 +37,21 @@ var embeddedPromptPowerShell string
 //go:embed ev.cmd
 var embeddedBootstrapBat string
 
+//go:embed starship/envirou.toml
+var embeddedStarshipModule string
+
 func main() {
-	cmd.Execute(embeddedBootstrapBash, embeddedBootstrapPowerShell, embeddedPromptPowerShell, embeddedBootstrapBat)
+	cmd.Execute(cmd.Scripts{
+		Bash:             embeddedBootstrapBash,
+		BashPrompt:       embeddedPromptBash,
+		ZshPrompt:        embeddedPromptZsh,
+		Fish:             embeddedBootstrapFish,
+		Nushell:          embeddedBootstrapNushell,
+		Elvish:           embeddedBootstrapElvish,
+		Tcsh:             embeddedBootstrapTcsh,
+		PowerShell:       embeddedBootstrapPowerShell,
+		PowerShellPrompt: embeddedPromptPowerShell,
+		Bat:              embeddedBootstrapBat,
+		Starship:         embeddedStarshipModule,
+	})
 }
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/sverrirab/envirou/pkg/data"
@@ -12,15 +14,28 @@ type Configuration struct {
 	SettingsQuiet     bool
 	SettingsSortKeys  bool
 	SettingsPathTilde bool
+	SettingsTable     bool
 	SettingsPassword  data.Patterns
 	SettingsPath      data.Patterns
 
+	SettingsSecretDetection bool
+	SettingsMaskStyle       string
+
+	// Compiled once at load, shared by groups and the password and path settings
+	Patterns                *data.PatternSet
+	SettingsPasswordMatcher *data.CompiledPatterns
+	SettingsPathMatcher     *data.CompiledPatterns
+
 	FormatGroup   string
 	FormatProfile string
 	FormatEnvName string
 	FormatPath    string
 	FormatDiff    string
 
+	PromptFormat    string
+	PromptChanged   string
+	PromptSeparator string
+
 	Groups   data.Groups
 	Profiles data.Profiles
 }
@@ -34,12 +49,36 @@ func readFormat(config *ini.IniFile, name, defaultValue string) string {
 	}
 }
 
+// readGroupOptions reads the display options from a [group:NAME] section.
+func readGroupOptions(config *ini.IniFile, section string) (data.GroupOptions, error) {
+	options := data.GroupOptions{
+		Description: config.GetString(section, "description", ""),
+		Collapsed:   config.GetBool(section, "collapsed", false),
+	}
+	if order := config.GetString(section, "order", ""); order != "" {
+		value, err := strconv.Atoi(order)
+		if err != nil {
+			return options, fmt.Errorf("[%s] order: %q is not a number", section, order)
+		}
+		options.Order = value
+	}
+	if sortMode := config.GetString(section, "sort", ""); sortMode != "" {
+		value, err := data.ParseSortMode(sortMode)
+		if err != nil {
+			return options, fmt.Errorf("[%s] sort: %v", section, err)
+		}
+		options.SortMode = value
+		options.HasSortMode = true
+	}
+	return options, nil
+}
+
 func ReadConfiguration(configPath string, caseInsensitive bool) (*Configuration, error) {
 	configuration := &Configuration{
 		SettingsQuiet:     false,
 		SettingsSortKeys:  false,
 		SettingsPathTilde: false,
-		Groups:            make(data.Groups),
+		Groups:            *data.NewGroups(),
 		Profiles:          make(data.Profiles),
 	}
 	config, err := ini.NewIni(configPath)
@@ -57,8 +96,21 @@ func ReadConfiguration(configPath string, caseInsensitive bool) (*Configuration,
 	configuration.SettingsQuiet = config.GetBool("settings", "quiet", false)
 	configuration.SettingsSortKeys = config.GetBool("settings", "sort_keys", true)
 	configuration.SettingsPathTilde = config.GetBool("settings", "path_tilde", true)
+	configuration.SettingsTable = config.GetBool("settings", "table", true)
 	configuration.SettingsPassword = *data.ParsePatterns(config.GetString("settings", "password", ""), caseInsensitive)
+	if err := configuration.SettingsPassword.Validate(); err != nil {
+		return configuration, fmt.Errorf("[settings] password: %v", err)
+	}
 	configuration.SettingsPath = *data.ParsePatterns(config.GetString("settings", "path", ""), caseInsensitive)
+	if err := configuration.SettingsPath.Validate(); err != nil {
+		return configuration, fmt.Errorf("[settings] path: %v", err)
+	}
+
+	configuration.SettingsSecretDetection = config.GetBool("settings", "secret_detection", false)
+	configuration.SettingsMaskStyle = config.GetString("settings", "mask_style", output.MaskStyleFull)
+	if !output.IsValidMaskStyle(configuration.SettingsMaskStyle) {
+		return configuration, fmt.Errorf("[settings] mask_style: %q is not full or partial", configuration.SettingsMaskStyle)
+	}
 
 	configuration.FormatGroup = readFormat(config, "group", "magenta")
 	configuration.FormatProfile = readFormat(config, "profile", "green")
@@ -66,16 +118,48 @@ func ReadConfiguration(configPath string, caseInsensitive bool) (*Configuration,
 	configuration.FormatPath = readFormat(config, "path", "reverse")
 	configuration.FormatDiff = readFormat(config, "diff", "red")
 
+	configuration.PromptFormat = config.GetString("prompt", "format", DefaultPromptFormat)
+	configuration.PromptChanged = config.GetString("prompt", "changed", DefaultPromptChanged)
+	configuration.PromptSeparator = config.GetString("prompt", "separator", DefaultPromptSeparator)
+
 	// Groups
-	groups := config.GetAllVariables("groups")
-	for _, k := range groups {
-		configuration.Groups.ParseAndAdd(k, config.GetString("groups", k, ""), caseInsensitive)
+	configuration.Groups.Precedence, err = data.ParsePrecedence(config.GetString("settings", "group_precedence", ""))
+	if err != nil {
+		return configuration, fmt.Errorf("[settings] group_precedence: %v", err)
+	}
+	if !configuration.SettingsSortKeys {
+		configuration.Groups.SortMode = data.SortByDeclaration
+	}
+	for _, section := range []string{"groups", "custom"} {
+		for _, k := range config.GetVariablesInOrder(section) {
+			configuration.Groups.ParseAndAdd(k, config.GetString(section, k, ""), caseInsensitive)
+			patterns, _ := configuration.Groups.GetPatterns(k)
+			if err := patterns.Validate(); err != nil {
+				return configuration, fmt.Errorf("[%s] %s: %v", section, k, err)
+			}
+		}
 	}
-	custom := config.GetAllVariables("custom")
-	for _, k := range custom {
-		configuration.Groups.ParseAndAdd(k, config.GetString("custom", k, ""), caseInsensitive)
+
+	for _, section := range config.GetAllSections() {
+		split := strings.SplitN(section, ":", 2)
+		if len(split) == 2 && strings.TrimSpace(strings.ToLower(split[0])) == "group" {
+			groupName := strings.TrimSpace(split[1])
+			options, err := readGroupOptions(config, section)
+			if err != nil {
+				return configuration, err
+			}
+			if _, found := configuration.Groups.GetPatterns(groupName); !found {
+				output.Printf("Warning: options for unknown group %s in [%s]\n", groupName, section)
+			}
+			configuration.Groups.SetOptions(groupName, options)
+		}
 	}
 
+	configuration.Patterns = data.NewPatternSet(caseInsensitive)
+	configuration.Groups.Compile(configuration.Patterns)
+	configuration.SettingsPasswordMatcher = configuration.Patterns.Add(&configuration.SettingsPassword)
+	configuration.SettingsPathMatcher = configuration.Patterns.Add(&configuration.SettingsPath)
+
 	for _, dup := range config.Duplicates {
 		if strings.HasPrefix(dup.Section, "profile:") {
 			output.Printf("Warning: duplicate variable %s in [%s] (only last value is used)\n", dup.Variable, dup.Section)
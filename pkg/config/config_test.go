@@ -4,6 +4,8 @@ import (
 	"log"
 	"os"
 	"testing"
+
+	"github.com/sverrirab/envirou/pkg/data"
 )
 
 const testConfig = `
@@ -11,6 +13,7 @@ const testConfig = `
 [settings]
 quiet=1
 sort_keys=0
+table=0
 password=FOO*, BAR, *MATCH
 
 [format]
@@ -74,6 +77,12 @@ func TestReadConfig(t *testing.T) {
 	if config.SettingsPathTilde != true {
 		t.Error("PathTilde should be false")
 	}
+	if config.SettingsTable != false {
+		t.Error("Table should be false")
+	}
+	if config.SettingsSecretDetection || config.SettingsMaskStyle != "full" {
+		t.Errorf("Secret detection should be off by default: %v %s", config.SettingsSecretDetection, config.SettingsMaskStyle)
+	}
 	if config.FormatGroup != "magenta" {
 		t.Errorf("expected magenta")
 	}
@@ -89,8 +98,8 @@ func TestReadConfig(t *testing.T) {
 	if len(config.SettingsPath) != 0 {
 		t.Errorf("Unexpected path: %s", config.SettingsPath)
 	}
-	if len(config.Groups) != 3 {
-		t.Errorf("Unexpeced number of groups: %d", len(config.Groups))
+	if config.Groups.Len() != 3 {
+		t.Errorf("Unexpeced number of groups: %d", config.Groups.Len())
 	}
 }
 
@@ -122,6 +131,9 @@ func TestReadDefault(t *testing.T) {
 	if config.SettingsPathTilde != true {
 		t.Error("PathTilde should be false")
 	}
+	if config.SettingsTable != true {
+		t.Error("Table should be true by default")
+	}
 	if config.FormatGroup != "magenta" {
 		t.Error("expected magenta")
 	}
@@ -137,8 +149,8 @@ func TestReadDefault(t *testing.T) {
 	if len(config.SettingsPath) != 6 {
 		t.Errorf("Unexpected path: %s", config.SettingsPath)
 	}
-	if len(config.Groups) != 15 {
-		t.Errorf("Unexpected number of groups: %d", len(config.Groups))
+	if config.Groups.Len() != 15 {
+		t.Errorf("Unexpected number of groups: %d", config.Groups.Len())
 	}
 	removeFile(file.Name())
 }
@@ -177,3 +189,88 @@ func TestProfile(t *testing.T) {
 	validateProfileNil(t, config, "foo", "NOT-THREE", false)
 
 }
+
+func TestReadConfigInvalidPattern(t *testing.T) {
+	for _, invalid := range []string{
+		"[groups]\nbad=FOO[\n",
+		"[custom]\nbad=re:(\n",
+		"[settings]\npassword=re:[\n",
+		"[settings]\npath=PATH\\\n",
+		"[settings]\nmask_style=some\n",
+	} {
+		file, err := os.CreateTemp("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = file.WriteString(invalid)
+		_ = file.Close()
+		_, err = ReadConfiguration(file.Name(), false)
+		removeFile(file.Name())
+		if err == nil {
+			t.Errorf("Expected error for config: %q", invalid)
+		}
+	}
+}
+
+func TestReadConfigGlobPatterns(t *testing.T) {
+	config := readTestConfig(t, "[groups]\naws=AWS_*_KEY, re:EC2_(URL|REGION)\n")
+	patterns, found := config.Groups.GetPatterns("aws")
+	if !found {
+		t.Fatal("Missing aws group")
+	}
+	if !data.MatchAny("AWS_SECRET_KEY", patterns, false) || !data.MatchAny("EC2_URL", patterns, false) {
+		t.Errorf("Patterns do not match: %v", patterns)
+	}
+}
+
+func TestReadConfigGroupPrecedence(t *testing.T) {
+	config := readTestConfig(t, "[settings]\ngroup_precedence=order\n[groups]\nb=FOO*\na=FOO\n[custom]\nc=*\n")
+	if config.Groups.Precedence != data.PrecedenceOrder {
+		t.Errorf("Unexpected precedence: %d", config.Groups.Precedence)
+	}
+	names := config.Groups.GetNamesInOrder()
+	if len(names) != 3 || names[0] != "b" || names[1] != "a" || names[2] != "c" {
+		t.Errorf("Unexpected declaration order: %v", names)
+	}
+}
+
+func TestReadConfigGroupOptions(t *testing.T) {
+	config := readTestConfig(t, `
+[settings]
+sort_keys=0
+[groups]
+aws=AWS_*
+cloud=KUBE_*
+[group:aws]
+order=-1
+description=Amazon
+collapsed=1
+sort=length
+`)
+	options := config.Groups.GetOptions("aws")
+	if options.Order != -1 || options.Description != "Amazon" || !options.Collapsed || options.SortMode != data.SortByValueLength || !options.HasSortMode {
+		t.Errorf("Unexpected options: %+v", options)
+	}
+	if names := config.Groups.GetDisplayNames(); names[0] != "aws" {
+		t.Errorf("Unexpected display order: %v", names)
+	}
+	if config.Groups.SortMode != data.SortByDeclaration {
+		t.Error("sort_keys=0 should sort by declaration")
+	}
+}
+
+func TestReadConfigInvalidGroupOptions(t *testing.T) {
+	for _, invalid := range []string{"[group:x]\norder=first\n", "[group:x]\nsort=random\n"} {
+		file, err := os.CreateTemp("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = file.WriteString(invalid)
+		_ = file.Close()
+		_, err = ReadConfiguration(file.Name(), false)
+		removeFile(file.Name())
+		if err == nil {
+			t.Errorf("Expected error for config: %q", invalid)
+		}
+	}
+}
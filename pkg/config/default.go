@@ -1,7 +1,6 @@
 package config
 
 import (
-	"io/ioutil"
 	"log"
 	"os"
 	"os/user"
@@ -16,10 +15,23 @@ const default_ini = `
 
 [settings]
 quiet=0
+; sort_keys=0 lists variables in the order of the group patterns instead of by name
 sort_keys=1
 path_tilde=1  ; display only: replaces $HOME with ~ in output
+; table=0 lists NAME=value lines instead of a table sized to the terminal
+table=1
 password=AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN
+; secret_detection=1 also masks names with a word ending in TOKEN, SECRET, PASSWORD
+; or PASSWD, names ending in APIKEY or in KEY after API, ACCESS, SECRET, PRIVATE,
+; SIGNING, ENCRYPTION, MASTER or AUTH (not GPG_KEY), known token prefixes, random
+; looking values and passwords inside URLs
+secret_detection=0
+; full: hide the whole value, partial: show the last four characters
+mask_style=full
 path=HOME, PATH, GOPATH, JAVA_HOME, KUBECONFIG, VIRTUAL_ENV
+; all: list variables under every matching group, order: first matching group,
+; specific: group with the most specific matching pattern
+group_precedence=all
 
 ; ── Display colors ───────────────────────────────────────────
 ; Valid colors: green, magenta, red, yellow, blue, cyan, white,
@@ -31,8 +43,20 @@ profile=green
 env_name=cyan
 path=reverse
 
+; ── Prompt ───────────────────────────────────────────────────
+; Used by "envirou prompt": {profiles} are the active profiles joined by the
+; separator, {changed} is replaced by the changed marker if the environment
+; changed since the last snapshot.
+
+[prompt]
+format=[{profiles}{changed}]
+changed=*
+separator=,
+
 ; ── Visible groups ───────────────────────────────────────────
-; Use * for wildcards. These groups are shown by default.
+; Use * and ? for wildcards, [...] for character classes or re: for a
+; regular expression. Prefix a pattern with ! to exclude matching names.
+; These groups are shown by default.
 
 [groups]
 basic=PATH
@@ -57,7 +81,7 @@ git=GIT_*
 ; ── Ignored groups (.. prefix) ───────────────────────────────
 ; Hidden and excluded from snapshot/diff.
 
-..ignore=_, PWD, OLDPWD, SHLVL
+..ignore=_, PWD, OLDPWD, SHLVL, ENVIROU_SUBSHELL
 
 ; ── Custom ───────────────────────────────────────────────────
 ; Add your customizations below this point.
@@ -66,6 +90,16 @@ git=GIT_*
 ; Add custom groups here.
 ; example=EXAMPLE_*
 
+; Optional display options for any group. Groups are displayed by ascending
+; order (default 0, ties keep the declared order). Collapsed groups only show
+; their header unless requested with -g or -a. Variables are sorted by name,
+; declaration (pattern order) or length (value length).
+; [group:example]
+; order=-1
+; description=Example variables
+; collapsed=1
+; sort=name
+
 ; Add your own profiles here...
 ; [profile:example]
 ; EXAMPLE_OCCUPATION=elevator operator
@@ -106,7 +140,7 @@ func WriteDefaultConfigFile(path string) error {
 	}
 	_, err = os.Stat(path)
 	if os.IsNotExist(err) {
-		err = ioutil.WriteFile(path, []byte(default_ini), 0644)
+		err = WriteFile(path, []byte(default_ini), 0644)
 	}
 	return err
 }
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// AddCustomGroups adds "name=patterns" entries to the [custom] section of the config file,
+// leaving all other lines (including comments) untouched. Entries are inserted after the last
+// variable in the section, or after the comment block following the section header if it has
+// no variables yet. A [custom] section is appended if the file does not have one.
+func AddCustomGroups(configPath string, entries []string) error {
+	return UpdateFile(configPath, 0644, func(existing []byte) ([]byte, error) {
+		return insertIntoSection(existing, "custom", entries), nil
+	})
+}
+
+func insertIntoSection(content []byte, section string, entries []string) []byte {
+	newline := "\n"
+	if bytes.Contains(content, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+
+	header := -1
+	insertAt := -1
+	headerComments := true
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		isHeader := len(trimmed) >= 2 && trimmed[0] == '[' && trimmed[len(trimmed)-1] == ']'
+		if header < 0 {
+			if isHeader && strings.TrimSpace(trimmed[1:len(trimmed)-1]) == section {
+				header = i
+				insertAt = i + 1
+			}
+			continue
+		}
+		if isHeader {
+			break
+		}
+		if trimmed == "" {
+			headerComments = false
+		} else if trimmed[0] == ';' || trimmed[0] == '#' {
+			if headerComments {
+				// Still in the comment block directly after the header
+				insertAt = i + 1
+			}
+		} else {
+			headerComments = false
+			insertAt = i + 1
+		}
+	}
+
+	if header < 0 {
+		text := strings.TrimRight(string(content), "\r\n")
+		if text != "" {
+			text += newline + newline
+		}
+		text += fmt.Sprintf("[%s]%s%s%s", section, newline, strings.Join(entries, newline), newline)
+		return []byte(text)
+	}
+	result := make([]string, 0, len(lines)+len(entries))
+	result = append(result, lines[:insertAt]...)
+	result = append(result, entries...)
+	result = append(result, lines[insertAt:]...)
+	return []byte(strings.Join(result, newline))
+}
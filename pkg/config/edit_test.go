@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInsertIntoSection(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			"after last variable",
+			"[groups]\na=A\n\n[custom]\n; comment\nb=B\n\n; profiles\n[profile:x]\nX=1\n",
+			"[groups]\na=A\n\n[custom]\n; comment\nb=B\nnew=NEW_*\n\n; profiles\n[profile:x]\nX=1\n",
+		},
+		{
+			"after header comments",
+			"[custom]\n; Add custom groups here.\n; example=EXAMPLE_*\n\n; Add your own profiles here...\n",
+			"[custom]\n; Add custom groups here.\n; example=EXAMPLE_*\nnew=NEW_*\n\n; Add your own profiles here...\n",
+		},
+		{
+			"missing section",
+			"[groups]\na=A\n",
+			"[groups]\na=A\n\n[custom]\nnew=NEW_*\n",
+		},
+		{
+			"windows line endings",
+			"[custom]\r\nb=B\r\n",
+			"[custom]\r\nb=B\r\nnew=NEW_*\r\n",
+		},
+		{
+			"empty file",
+			"",
+			"[custom]\nnew=NEW_*\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(insertIntoSection([]byte(tt.content), "custom", []string{"new=NEW_*"}))
+			if got != tt.expected {
+				t.Errorf("insertIntoSection() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAddCustomGroups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.ini")
+	if err := os.WriteFile(path, []byte(default_ini), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddCustomGroups(path, []string{"spark=SPARK_*"}); err != nil {
+		t.Fatal(err)
+	}
+	config, err := ReadConfiguration(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := config.Groups.GetPatterns("spark"); !found {
+		t.Error("Expected spark group to be added")
+	}
+}
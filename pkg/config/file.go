@@ -0,0 +1,118 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const lockFileSuffix = ".lock"
+
+// WriteFile atomically replaces path with content while holding an advisory lock.
+// The content is written to a temporary file in the same folder which is then renamed
+// over the target, so readers never see a partially written file. If the file already
+// exists its permissions are preserved, otherwise perm is used.
+func WriteFile(path string, content []byte, perm os.FileMode) error {
+	return UpdateFile(path, perm, func([]byte) ([]byte, error) {
+		return content, nil
+	})
+}
+
+// AppendFile atomically appends content to path while holding an advisory lock.
+func AppendFile(path string, content []byte, perm os.FileMode) error {
+	return UpdateFile(path, perm, func(existing []byte) ([]byte, error) {
+		return append(existing, content...), nil
+	})
+}
+
+// UpdateFile reads path (empty if missing), passes the content to update and atomically
+// writes the result back. The whole read-modify-write cycle runs under an advisory lock
+// on a sidecar "<path>.lock" file so concurrent envirou processes do not interleave.
+// If path is a symlink the file it points to is updated and the link is kept.
+func UpdateFile(path string, perm os.FileMode, update func(existing []byte) ([]byte, error)) error {
+	path, err := resolvePath(path)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockPath(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	content, err := update(existing)
+	if err != nil {
+		return err
+	}
+	return replaceFile(path, content, perm)
+}
+
+// resolvePath follows symlinks so the target is replaced rather than the link. A path that
+// does not exist yet is returned unchanged.
+func resolvePath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return path, nil
+	}
+	return resolved, err
+}
+
+// replaceFile writes content to a temporary file next to path and renames it into place.
+func replaceFile(path string, content []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			_ = os.Remove(tmpName)
+		}
+	}()
+
+	if _, err = tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpName, path); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// lockPath takes an exclusive advisory lock for path and returns the function that releases it.
+// The lock is held on a separate file because the target itself is replaced by rename.
+func lockPath(path string) (func(), error) {
+	f, err := os.OpenFile(path+lockFileSuffix, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = lockFile(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return func() {
+		_ = unlockFile(f)
+		_ = f.Close()
+	}, nil
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	helperEnvFile   = "ENVIROU_TEST_APPEND_FILE"
+	helperEnvWriter = "ENVIROU_TEST_APPEND_WRITER"
+	appendsPerProc  = 50
+)
+
+func TestWriteFileReplaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.ini")
+	if err := WriteFile(path, []byte("one"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, []byte("two"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "two" {
+		t.Errorf("Expected file to be replaced, got: %s", b)
+	}
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp"))
+	if len(matches) != 0 {
+		t.Errorf("Temporary files left behind: %v", matches)
+	}
+}
+
+func TestWriteFilePreservesPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permissions only")
+	}
+	path := filepath.Join(t.TempDir(), "config.ini")
+	if err := os.WriteFile(path, []byte("one"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, []byte("two"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600 to be preserved, got %o", info.Mode().Perm())
+	}
+}
+
+func TestWriteFileKeepsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "config.ini")
+	if err := os.Mkdir(filepath.Dir(target), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte("one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "config.ini")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("Symlinks not supported:", err)
+	}
+	if err := WriteFile(link, []byte("two"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected %s to still be a symlink", link)
+	}
+	b, _ := os.ReadFile(target)
+	if string(b) != "two" {
+		t.Errorf("Expected the target to be replaced, got: %s", b)
+	}
+}
+
+func TestAppendFileCreates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.ini")
+	if err := AppendFile(path, []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendFile(path, []byte("b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(path)
+	if string(b) != "a\nb\n" {
+		t.Errorf("Unexpected content: %q", b)
+	}
+}
+
+func TestAppendFileParallelGoroutines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.ini")
+	const writers = 8
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			if err := appendLines(path, strconv.Itoa(w)); err != nil {
+				t.Error(err)
+			}
+		}(w)
+	}
+	wg.Wait()
+	verifyAppendedLines(t, path, writers)
+}
+
+func TestAppendFileParallelProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.ini")
+	const writers = 4
+	cmds := make([]*exec.Cmd, 0, writers)
+	for w := 0; w < writers; w++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestAppendFileHelperProcess$")
+		cmd.Env = append(os.Environ(), helperEnvFile+"="+path, helperEnvWriter+"="+strconv.Itoa(w))
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		cmds = append(cmds, cmd)
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Errorf("Helper process failed: %v", err)
+		}
+	}
+	verifyAppendedLines(t, path, writers)
+}
+
+// TestAppendFileHelperProcess is not a real test, it is run as a child process by
+// TestAppendFileParallelProcesses.
+func TestAppendFileHelperProcess(t *testing.T) {
+	path := os.Getenv(helperEnvFile)
+	if path == "" {
+		return
+	}
+	if err := appendLines(path, os.Getenv(helperEnvWriter)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func appendLines(path, writer string) error {
+	for i := 0; i < appendsPerProc; i++ {
+		line := fmt.Sprintf("%s-%d-%s\n", writer, i, strings.Repeat("x", 256))
+		if err := AppendFile(path, []byte(line), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyAppendedLines(t *testing.T, path string, writers int) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		parts := strings.SplitN(line, "-", 3)
+		if len(parts) != 3 || parts[2] != strings.Repeat("x", 256) {
+			t.Fatalf("Corrupted line: %q", line)
+		}
+		seen[parts[0]+"-"+parts[1]] = true
+	}
+	if len(seen) != writers*appendsPerProc {
+		t.Errorf("Expected %d lines, got %d", writers*appendsPerProc, len(seen))
+	}
+}
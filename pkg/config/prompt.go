@@ -0,0 +1,241 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sverrirab/envirou/pkg/data"
+)
+
+const (
+	promptCacheFileName = "prompt.cache"
+	// promptCacheVersion is increased when the cache format changes, older caches are rebuilt
+	promptCacheVersion = 2
+)
+
+// Defaults for the [prompt] settings.
+const (
+	DefaultPromptFormat    = "[{profiles}{changed}]"
+	DefaultPromptChanged   = "*"
+	DefaultPromptSeparator = ","
+)
+
+// PromptCache holds what `ev prompt` needs so it can run without parsing the config file.
+// It is only valid while the config and snapshot files are unchanged. Values are stored
+// as salted hashes so the cache does not hold secrets from the profiles or the snapshot.
+type PromptCache struct {
+	Version         int
+	Salt            string
+	ConfigPath      string
+	ConfigModTime   int64
+	ConfigSize      int64
+	SnapshotModTime int64 // Zero if there is no snapshot
+
+	Format    string
+	Changed   string
+	Separator string
+
+	Ignored  map[string]string // Ignored group name to its patterns
+	Profiles map[string][]PromptCacheEntry
+	Snapshot map[string]string // Variable name to hashed value
+}
+
+// PromptCacheEntry is one variable of a cached profile.
+type PromptCacheEntry struct {
+	Name  string
+	Value string `json:",omitempty"` // Hashed value
+	Mode  int    `json:",omitempty"`
+	Nil   bool   `json:",omitempty"`
+}
+
+// GetPromptCacheFilePath returns the full path to the prompt cache file
+func GetPromptCacheFilePath() string {
+	return filepath.Join(GetDefaultConfigFileFolder(), promptCacheFileName)
+}
+
+// NewPromptCache collects the state needed by the prompt from a loaded configuration.
+func NewPromptCache(configPath string, configuration *Configuration, snapshot *data.Profile) *PromptCache {
+	cache := &PromptCache{
+		Version:    promptCacheVersion,
+		Salt:       newSalt(),
+		ConfigPath: configPath,
+		Format:     configuration.PromptFormat,
+		Changed:    configuration.PromptChanged,
+		Separator:  configuration.PromptSeparator,
+		Ignored:    make(map[string]string),
+		Profiles:   make(map[string][]PromptCacheEntry),
+	}
+	cache.ConfigModTime, cache.ConfigSize = fileStamp(configPath)
+	cache.SnapshotModTime, _ = fileStamp(GetSnapshotFilePath())
+	for _, name := range configuration.Groups.GetAllNames() {
+		if strings.HasPrefix(name, "..") {
+			patterns, _ := configuration.Groups.GetPatterns(name)
+			parts := make([]string, 0, len(*patterns))
+			for _, pattern := range *patterns {
+				parts = append(parts, string(pattern))
+			}
+			cache.Ignored[name] = strings.Join(parts, ",")
+		}
+	}
+	for name, profile := range configuration.Profiles {
+		entries := make([]PromptCacheEntry, 0)
+		for _, variable := range profile.SortedNames(true) {
+			value, _ := profile.Get(variable)
+			entries = append(entries, PromptCacheEntry{
+				Name:  variable,
+				Value: cache.hash(value),
+				Mode:  profile.GetMergeMode(variable),
+				Nil:   profile.GetNil(variable),
+			})
+		}
+		cache.Profiles[name] = entries
+	}
+	if snapshot != nil {
+		cache.Snapshot = make(map[string]string)
+		for _, name := range snapshot.SortedNames(false) {
+			value, _ := snapshot.Get(name)
+			cache.Snapshot[name] = cache.hash(value)
+		}
+	}
+	return cache
+}
+
+// LoadPromptCache returns the cached prompt state if it is still valid for configPath.
+func LoadPromptCache(configPath string) (*PromptCache, bool) {
+	content, err := os.ReadFile(GetPromptCacheFilePath())
+	if err != nil {
+		return nil, false
+	}
+	var cache PromptCache
+	if err := json.Unmarshal(content, &cache); err != nil {
+		return nil, false
+	}
+	modTime, size := fileStamp(configPath)
+	snapshotModTime, _ := fileStamp(GetSnapshotFilePath())
+	if cache.Version != promptCacheVersion || cache.ConfigPath != configPath || cache.ConfigModTime != modTime || cache.ConfigSize != size || cache.SnapshotModTime != snapshotModTime {
+		return nil, false
+	}
+	return &cache, true
+}
+
+// SavePromptCache writes the prompt cache, readable only by the user.
+func SavePromptCache(cache *PromptCache) error {
+	content, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(GetDefaultConfigFileFolder(), os.ModePerm); err != nil {
+		return err
+	}
+	path := GetPromptCacheFilePath()
+	if err := WriteFile(path, content, 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the permissions of a cache written by an older version
+	return os.Chmod(path, 0600)
+}
+
+// newSalt returns a random salt for the hashes of a new cache.
+func newSalt() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// hash returns the salted hash of value. Each path component is hashed separately, so a
+// hashed profile that prepends or appends to a path is still found in a hashed path, and
+// two values have the same hash only if they are equal.
+func (cache *PromptCache) hash(value string) string {
+	sep := string(os.PathListSeparator)
+	parts := strings.Split(value, sep)
+	for i, part := range parts {
+		if part != "" {
+			sum := sha256.Sum256([]byte(cache.Salt + part))
+			parts[i] = hex.EncodeToString(sum[:12])
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// Evaluate returns the sorted names of the profiles active in env and true if env
+// has changed since the snapshot (ignored variables excluded).
+func (cache *PromptCache) Evaluate(env *data.Profile, caseInsensitive bool) ([]string, bool) {
+	// Compare hashes with hashes
+	hashed := data.NewProfile(caseInsensitive)
+	for _, name := range env.SortedNames(false) {
+		value, _ := env.Get(name)
+		hashed.Set(name, cache.hash(value))
+	}
+	env = hashed
+
+	active := make([]string, 0)
+	for name, entries := range cache.Profiles {
+		profile := data.NewProfile(caseInsensitive)
+		for _, entry := range entries {
+			if entry.Nil {
+				profile.SetNil(entry.Name)
+			} else {
+				profile.SetWithMode(entry.Name, entry.Value, entry.Mode)
+			}
+		}
+		if env.IsMerged(profile) {
+			active = append(active, name)
+		}
+	}
+	sort.Strings(active)
+
+	if cache.Snapshot == nil {
+		return active, false
+	}
+	snapshot := data.NewProfile(caseInsensitive)
+	for name, value := range cache.Snapshot {
+		snapshot.Set(name, value)
+	}
+	groups := data.NewGroups()
+	for name, patterns := range cache.Ignored {
+		groups.ParseAndAdd(name, patterns, caseInsensitive)
+	}
+	added, changed, removed := data.FullDiff(env, snapshot)
+	for _, names := range [][]string{added, changed, removed} {
+		for _, name := range names {
+			if !groups.IsIgnored(name, caseInsensitive) {
+				return active, true
+			}
+		}
+	}
+	return active, false
+}
+
+// FormatPrompt replaces {profiles} and {changed} in format. The result is empty if
+// no profile is active and nothing changed.
+func FormatPrompt(format, changedMarker, separator string, active []string, changed bool) string {
+	if len(active) == 0 && !changed {
+		return ""
+	}
+	marker := ""
+	if changed {
+		marker = changedMarker
+	}
+	return strings.NewReplacer("{profiles}", strings.Join(active, separator), "{changed}", marker).Replace(format)
+}
+
+// Sprint returns the prompt for env.
+func (cache *PromptCache) Sprint(env *data.Profile, caseInsensitive bool) string {
+	active, changed := cache.Evaluate(env, caseInsensitive)
+	return FormatPrompt(cache.Format, cache.Changed, cache.Separator, active, changed)
+}
+
+// fileStamp returns the modification time and size of a file, zeros if it does not exist.
+func fileStamp(path string) (int64, int64) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0
+	}
+	return info.ModTime().UnixNano(), info.Size()
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sverrirab/envirou/pkg/data"
+)
+
+const testPromptConfig = `
+[prompt]
+format=({profiles}{changed})
+changed=!
+separator=+
+
+[groups]
+..ignore=IGNORED_*
+
+[profile:dev]
+APP_ENV=development
+
+[profile:tools]
+PATH+=/opt/tools/bin
+
+[profile:clean]
+APP_DEBUG
+`
+
+func TestPromptCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.ini")
+	if err := os.WriteFile(path, []byte(testPromptConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configuration, err := ReadConfiguration(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := data.NewProfile(false)
+	snapshot.Set("APP_ENV", "development")
+	snapshot.Set("PATH", "/usr/bin:/opt/tools/bin")
+	cache := NewPromptCache(path, configuration, snapshot)
+
+	env := data.NewProfile(false)
+	env.Set("APP_ENV", "development")
+	env.Set("PATH", "/usr/bin:/opt/tools/bin")
+	env.Set("IGNORED_VAR", "x")
+	if prompt := cache.Sprint(env, false); prompt != "(clean+dev+tools)" {
+		t.Errorf("Expected all profiles active and unchanged, got %q", prompt)
+	}
+	env.Set("APP_DEBUG", "1")
+	if prompt := cache.Sprint(env, false); prompt != "(dev+tools!)" {
+		t.Errorf("Expected changed marker, got %q", prompt)
+	}
+
+	if err := SavePromptCache(cache); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(GetPromptCacheFilePath())
+	content, _ := os.ReadFile(GetPromptCacheFilePath())
+	if strings.Contains(string(content), "development") || strings.Contains(string(content), "/opt/tools/bin") {
+		t.Errorf("Expected only hashed values in the cache, got: %s", content)
+	}
+	if info, err := os.Stat(GetPromptCacheFilePath()); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the cache to be readable only by the user, got %o", info.Mode().Perm())
+	}
+	loaded, found := LoadPromptCache(path)
+	if !found {
+		t.Fatal("Expected cache to be valid")
+	}
+	if prompt := loaded.Sprint(env, false); prompt != "(dev+tools!)" {
+		t.Errorf("Expected same prompt from loaded cache, got %q", prompt)
+	}
+	if _, found := LoadPromptCache(path + ".other"); found {
+		t.Error("Expected cache for another config file to be invalid")
+	}
+
+	if err := os.WriteFile(path, []byte(testPromptConfig+"\n[profile:new]\nNEW=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := LoadPromptCache(path); found {
+		t.Error("Expected cache to be invalid after the config file changed")
+	}
+}
+
+func TestFormatPrompt(t *testing.T) {
+	tests := []struct {
+		active  []string
+		changed bool
+		want    string
+	}{
+		{nil, false, ""},
+		{nil, true, "[*]"},
+		{[]string{"dev"}, false, "[dev]"},
+		{[]string{"aws", "dev"}, true, "[aws,dev*]"},
+	}
+	for _, test := range tests {
+		got := FormatPrompt(DefaultPromptFormat, DefaultPromptChanged, DefaultPromptSeparator, test.active, test.changed)
+		if got != test.want {
+			t.Errorf("FormatPrompt(%v, %v) = %q, want %q", test.active, test.changed, got, test.want)
+		}
+	}
+}
@@ -26,7 +26,7 @@ func SaveSnapshot(profile *data.Profile, groups *data.Groups, caseInsensitive bo
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(GetSnapshotFilePath(), []byte(b.String()), 0644)
+	return WriteFile(GetSnapshotFilePath(), []byte(b.String()), 0644)
 }
 
 func LoadSnapshot(caseInsensitive bool) (*data.Profile, error) {
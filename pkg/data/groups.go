@@ -6,51 +6,217 @@ import (
 	"strings"
 )
 
-type Groups map[string]Patterns
+// Precedence decides which groups a variable is listed under when several match.
+const (
+	PrecedenceAll      = iota // Default: listed under every matching group
+	PrecedenceOrder           // Listed under the first matching group in declaration order
+	PrecedenceSpecific        // Listed under the group with the most specific matching pattern
+)
+
+// SortMode decides the order of variables within a group.
+const (
+	SortByName        = iota // Default: alphabetical
+	SortByDeclaration        // In the order of the first matching pattern
+	SortByValueLength        // Shortest value first
+)
+
+// GroupOptions holds the display options from a [group:NAME] section.
+type GroupOptions struct {
+	Order       int    // Groups are displayed by ascending order, ties keep declaration order
+	Description string // Shown by the groups command
+	Collapsed   bool   // Only the group header is displayed unless the group is requested
+	SortMode    int    // SortByName, SortByDeclaration or SortByValueLength
+	HasSortMode bool   // SortMode was set explicitly, otherwise Groups.SortMode applies
+}
+
+type Groups struct {
+	patterns   map[string]Patterns
+	order      []string // Group names in declaration order
+	options    map[string]GroupOptions
+	Precedence int // PrecedenceAll, PrecedenceOrder or PrecedenceSpecific
+	SortMode   int // Default SortMode for groups without one
+
+	set      *PatternSet                  // Set the groups are compiled into (nil until needed)
+	compiled map[string]*CompiledPatterns // Group name to compiled patterns in set
+}
 type Envs []string
 type GroupNameToEnvs map[string]Envs
 
 func NewGroups() *Groups {
-	g := make(map[string]Patterns)
-	return (*Groups)(&g)
+	return &Groups{
+		patterns:   make(map[string]Patterns),
+		order:      make([]string, 0),
+		options:    make(map[string]GroupOptions),
+		Precedence: PrecedenceAll,
+		SortMode:   SortByName,
+	}
+}
+
+// ParseSortMode maps a group "sort" option to a SortMode value.
+func ParseSortMode(value string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "name":
+		return SortByName, nil
+	case "declaration", "declared":
+		return SortByDeclaration, nil
+	case "length", "value_length":
+		return SortByValueLength, nil
+	}
+	return SortByName, fmt.Errorf("unknown sort mode %q (use name, declaration or length)", value)
 }
 
+// ParsePrecedence maps the "group_precedence" setting to a Precedence value.
+func ParsePrecedence(value string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "all":
+		return PrecedenceAll, nil
+	case "order", "first":
+		return PrecedenceOrder, nil
+	case "specific":
+		return PrecedenceSpecific, nil
+	}
+	return PrecedenceAll, fmt.Errorf("unknown group precedence %q (use all, order or specific)", value)
+}
+
+// ParseAndAdd parses patterns and adds (or replaces) a group, keeping its original declaration position.
 func (groups *Groups) ParseAndAdd(name string, patterns string, caseInsensitive bool) {
-	(*groups)[name] = *ParsePatterns(patterns, caseInsensitive)
+	if _, exists := groups.patterns[name]; !exists {
+		groups.order = append(groups.order, name)
+	}
+	groups.patterns[name] = *ParsePatterns(patterns, caseInsensitive)
+	groups.set = nil
+}
+
+// Compile compiles all groups into set, which may be shared with other pattern lists.
+func (groups *Groups) Compile(set *PatternSet) {
+	groups.set = set
+	groups.compiled = make(map[string]*CompiledPatterns, len(groups.patterns))
+	for _, name := range groups.order {
+		patterns := groups.patterns[name]
+		groups.compiled[name] = set.Add(&patterns)
+	}
+}
+
+// getCompiled returns the compiled groups, compiling them into a new set if needed.
+func (groups *Groups) getCompiled(caseInsensitive bool) map[string]*CompiledPatterns {
+	if groups.set == nil || groups.set.caseInsensitive != caseInsensitive {
+		groups.Compile(NewPatternSet(caseInsensitive))
+	}
+	return groups.compiled
 }
 
 func (groups *Groups) GetPatterns(name string) (*Patterns, bool) {
-	g, found := (*groups)[name]
+	g, found := groups.patterns[name]
 	if !found {
 		return nil, false
 	}
 	return &g, true
 }
 
+// SetOptions sets the display options for a group.
+func (groups *Groups) SetOptions(name string, options GroupOptions) {
+	groups.options[name] = options
+}
+
+// GetOptions returns the display options for a group (zero value if none were set).
+func (groups *Groups) GetOptions(name string) GroupOptions {
+	return groups.options[name]
+}
+
+// Len returns the number of groups.
+func (groups *Groups) Len() int {
+	return len(groups.patterns)
+}
+
 // GetAllNames returns all names sorted.
 func (groups *Groups) GetAllNames() []string {
-	keys := make([]string, 0, len(*groups))
-	for key := range *groups {
+	keys := make([]string, 0, len(groups.patterns))
+	for key := range groups.patterns {
 		keys = append(keys, key)
 	}
 	sort.Strings(keys)
 	return keys
 }
 
+// GetNamesInOrder returns all names in declaration order.
+func (groups *Groups) GetNamesInOrder() []string {
+	names := make([]string, len(groups.order))
+	copy(names, groups.order)
+	return names
+}
+
+// GetDisplayNames returns all names ordered by their Order option, ties keep declaration order.
+func (groups *Groups) GetDisplayNames() []string {
+	names := groups.GetNamesInOrder()
+	sort.SliceStable(names, func(i, j int) bool {
+		return groups.options[names[i]].Order < groups.options[names[j]].Order
+	})
+	return names
+}
+
+// SortEnvs returns the variables of a group sorted according to its sort mode.
+func (groups *Groups) SortEnvs(name string, envs Envs, profile *Profile, caseInsensitive bool) Envs {
+	sorted := make(Envs, len(envs))
+	copy(sorted, envs)
+	mode := groups.SortMode
+	if options, found := groups.options[name]; found && options.HasSortMode {
+		mode = options.SortMode
+	}
+	switch mode {
+	case SortByDeclaration:
+		patterns, _ := groups.GetPatterns(name)
+		index := make(map[string]int, len(sorted))
+		for _, env := range sorted {
+			index[env] = firstMatchingPattern(env, patterns, caseInsensitive)
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if index[sorted[i]] != index[sorted[j]] {
+				return index[sorted[i]] < index[sorted[j]]
+			}
+			return sorted[i] < sorted[j]
+		})
+	case SortByValueLength:
+		sort.SliceStable(sorted, func(i, j int) bool {
+			a, _ := profile.Get(sorted[i])
+			b, _ := profile.Get(sorted[j])
+			if len(a) != len(b) {
+				return len(a) < len(b)
+			}
+			return sorted[i] < sorted[j]
+		})
+	default:
+		sort.Strings(sorted)
+	}
+	return sorted
+}
+
+// firstMatchingPattern returns the index of the first non-exclusion pattern matching env.
+func firstMatchingPattern(env string, patterns *Patterns, caseInsensitive bool) int {
+	if patterns == nil {
+		return 0
+	}
+	for i, p := range *patterns {
+		if !p.IsExclusion() && Match(env, p, caseInsensitive) {
+			return i
+		}
+	}
+	return len(*patterns)
+}
+
 func (groups Groups) String() string {
 	names := groups.GetAllNames()
 	result := make([]string, 0, len(names))
 	for _, name := range names {
-		result = append(result, fmt.Sprintf("%s=%s", name, groups[name]))
+		result = append(result, fmt.Sprintf("%s=%s", name, groups.patterns[name]))
 	}
 	return strings.Join(result, " | ")
 }
 
 // IsIgnored returns true if name matches any group whose name starts with ".."
 func (groups *Groups) IsIgnored(name string, caseInsensitive bool) bool {
-	for groupName, patterns := range *groups {
+	for groupName, compiled := range groups.getCompiled(caseInsensitive) {
 		if strings.HasPrefix(groupName, "..") {
-			if MatchAny(name, &patterns, caseInsensitive) {
+			if compiled.MatchAny(name) {
 				return true
 			}
 		}
@@ -58,28 +224,41 @@ func (groups *Groups) IsIgnored(name string, caseInsensitive bool) bool {
 	return false
 }
 
-// MatchAll returns a map of group names to all env variables as well as a list of unmatched ones
+// MatchAll returns a map of group names to all env variables as well as a list of unmatched ones.
+// With PrecedenceOrder or PrecedenceSpecific each variable is assigned to a single group.
 func (groups *Groups) MatchAll(envs Envs, caseInsensitive bool) (GroupNameToEnvs, Envs) {
-	result := make(GroupNameToEnvs, len(*groups))
-	matched := make(map[string]bool, len(*groups))
+	result := make(GroupNameToEnvs, len(groups.patterns))
 	unmatched := make(Envs, 0)
+	compiled := groups.getCompiled(caseInsensitive)
+	names := groups.GetAllNames()
+	if groups.Precedence != PrecedenceAll {
+		names = groups.GetNamesInOrder()
+	}
 
 	for _, env := range envs {
-		for _, group := range groups.GetAllNames() {
-			patterns, found := groups.GetPatterns(group)
-			if !found {
+		matched := false
+		bestGroup := ""
+		bestSpecificity := -1
+		results := groups.set.Match(env)
+		for _, group := range names {
+			specificity := results[compiled[group].id]
+			if specificity < 0 {
 				continue
 			}
-			if MatchAny(env, patterns, caseInsensitive) {
-				matched[env] = true
+			matched = true
+			if groups.Precedence == PrecedenceAll {
 				result[group] = append(result[group], env)
+			} else if specificity > bestSpecificity {
+				bestGroup, bestSpecificity = group, specificity
+				if groups.Precedence == PrecedenceOrder {
+					break
+				}
 			}
 		}
-	}
-	for _, env := range envs {
-		_, found := matched[env]
-		if !found {
+		if !matched {
 			unmatched = append(unmatched, env)
+		} else if bestGroup != "" {
+			result[bestGroup] = append(result[bestGroup], env)
 		}
 	}
 	return result, unmatched
@@ -59,3 +59,141 @@ func TestStringer(t *testing.T) {
 		t.Errorf("Stringer interface changed: \"%s\"", g)
 	}
 }
+
+func TestMatchAllExclusion(t *testing.T) {
+	g := NewGroups()
+	g.ParseAndAdd("aws", "AWS_*, !AWS_PROFILE", false)
+	m, r := g.MatchAll([]string{"AWS_REGION", "AWS_PROFILE"}, false)
+	if len(m["aws"]) != 1 || m["aws"][0] != "AWS_REGION" {
+		t.Errorf("Expected only AWS_REGION in aws: %s", m["aws"])
+	}
+	if len(r) != 1 || r[0] != "AWS_PROFILE" {
+		t.Errorf("Expected AWS_PROFILE to be unmatched: %s", r)
+	}
+}
+
+func TestMatchAllPrecedenceOrder(t *testing.T) {
+	g := NewGroups()
+	g.ParseAndAdd("zero", "SMURF, DURF", false)
+	g.ParseAndAdd("foo", "*FOO*", false)
+	g.ParseAndAdd("bar", "FOO*, SMURF", false)
+	g.Precedence = PrecedenceOrder
+
+	m, r := g.MatchAll([]string{"SMURF", "FOOBAR", "bob"}, false)
+	if len(m["zero"]) != 1 || m["zero"][0] != "SMURF" {
+		t.Errorf("Expected SMURF in first declared group: %v", m)
+	}
+	if len(m["foo"]) != 1 || m["foo"][0] != "FOOBAR" {
+		t.Errorf("Expected FOOBAR in foo: %v", m)
+	}
+	if len(m["bar"]) != 0 {
+		t.Errorf("Expected bar to be empty: %v", m)
+	}
+	if len(r) != 1 || r[0] != "bob" {
+		t.Error("Where is bob?")
+	}
+}
+
+func TestMatchAllPrecedenceSpecific(t *testing.T) {
+	g := NewGroups()
+	g.ParseAndAdd("all", "*", false)
+	g.ParseAndAdd("foo", "*FOO*", false)
+	g.ParseAndAdd("bar", "FOO*", false)
+	g.ParseAndAdd("exact", "FOOBAR", false)
+	g.Precedence = PrecedenceSpecific
+
+	m, _ := g.MatchAll([]string{"FOOBAR", "FOOX", "XFOOX", "OTHER"}, false)
+	expected := map[string]string{"FOOBAR": "exact", "FOOX": "bar", "XFOOX": "foo", "OTHER": "all"}
+	for env, group := range expected {
+		if len(m[group]) != 1 || m[group][0] != env {
+			t.Errorf("Expected %s in %s: %v", env, group, m)
+		}
+	}
+}
+
+func TestDeclarationOrder(t *testing.T) {
+	g := NewGroups()
+	g.ParseAndAdd("b", "B", false)
+	g.ParseAndAdd("a", "A", false)
+	g.ParseAndAdd("b", "BB", false)
+	names := g.GetNamesInOrder()
+	if len(names) != 2 || names[0] != "b" || names[1] != "a" {
+		t.Errorf("Unexpected order: %v", names)
+	}
+	if g.Len() != 2 {
+		t.Errorf("Expected 2 groups, got %d", g.Len())
+	}
+}
+
+func TestParsePrecedence(t *testing.T) {
+	for value, expected := range map[string]int{"": PrecedenceAll, "all": PrecedenceAll, "Order": PrecedenceOrder, "specific": PrecedenceSpecific} {
+		p, err := ParsePrecedence(value)
+		if err != nil || p != expected {
+			t.Errorf("ParsePrecedence(%q) = %d, %v", value, p, err)
+		}
+	}
+	if _, err := ParsePrecedence("random"); err == nil {
+		t.Error("Expected error for unknown precedence")
+	}
+}
+
+func TestDisplayNames(t *testing.T) {
+	g := NewGroups()
+	g.ParseAndAdd("c", "C", false)
+	g.ParseAndAdd("a", "A", false)
+	g.ParseAndAdd("b", "B", false)
+	g.ParseAndAdd("d", "D", false)
+	g.SetOptions("b", GroupOptions{Order: -1})
+	g.SetOptions("c", GroupOptions{Order: 5, Description: "see"})
+	names := g.GetDisplayNames()
+	expected := []string{"b", "a", "d", "c"}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("Unexpected display order: %v", names)
+		}
+	}
+	if g.GetOptions("c").Description != "see" || g.GetOptions("missing").Collapsed {
+		t.Error("Unexpected options")
+	}
+}
+
+func TestSortEnvs(t *testing.T) {
+	g := NewGroups()
+	g.ParseAndAdd("x", "Z*, A*, M", false)
+	profile := NewProfile(false)
+	profile.MergeStrings([]string{"A1=long value", "A2=x", "M=medium", "ZED=zz"})
+	envs := Envs{"A1", "A2", "M", "ZED"}
+
+	check := func(expected ...string) {
+		t.Helper()
+		sorted := g.SortEnvs("x", envs, profile, false)
+		for i := range expected {
+			if sorted[i] != expected[i] {
+				t.Fatalf("Expected %v, got %v", expected, sorted)
+			}
+		}
+	}
+	check("A1", "A2", "M", "ZED")
+	g.SetOptions("x", GroupOptions{SortMode: SortByDeclaration, HasSortMode: true})
+	check("ZED", "A1", "A2", "M")
+	g.SetOptions("x", GroupOptions{SortMode: SortByValueLength, HasSortMode: true})
+	check("A2", "ZED", "M", "A1")
+	g.SetOptions("x", GroupOptions{})
+	g.SortMode = SortByDeclaration
+	check("ZED", "A1", "A2", "M")
+	if envs[0] != "A1" {
+		t.Error("SortEnvs should not modify its input")
+	}
+}
+
+func TestParseSortMode(t *testing.T) {
+	for value, expected := range map[string]int{"": SortByName, "name": SortByName, "declaration": SortByDeclaration, "Length": SortByValueLength} {
+		mode, err := ParseSortMode(value)
+		if err != nil || mode != expected {
+			t.Errorf("ParseSortMode(%q) = %d, %v", value, mode, err)
+		}
+	}
+	if _, err := ParseSortMode("random"); err == nil {
+		t.Error("Expected error for unknown sort mode")
+	}
+}
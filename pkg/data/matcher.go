@@ -0,0 +1,203 @@
+package data
+
+import (
+	"strings"
+	"sync"
+)
+
+// PatternSet compiles many pattern lists into shared lookup structures so a name is
+// evaluated against all of them in a single pass. Exact names go into a map, FOO* and
+// *FOO patterns into prefix and (reversed) suffix tries, and only complex globs and
+// regular expressions fall back to Match. Results are cached per name.
+type PatternSet struct {
+	caseInsensitive bool
+	lists           int
+	exact           map[string][]patternRef
+	prefixes        *trieNode
+	suffixes        *trieNode
+	contains        []patternRef
+	general         []patternRef
+
+	mu    sync.Mutex
+	cache map[string][]int
+}
+
+// CompiledPatterns is a handle to one pattern list inside a PatternSet.
+type CompiledPatterns struct {
+	set *PatternSet
+	id  int
+}
+
+type patternRef struct {
+	list        int
+	exclude     bool
+	specificity int
+	literal     string  // Used by exact, prefix, suffix and contains matches
+	pattern     Pattern // Used by general matches
+}
+
+type trieNode struct {
+	children map[byte]*trieNode
+	refs     []patternRef
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{children: make(map[byte]*trieNode)}
+}
+
+func (node *trieNode) insert(key string, ref patternRef) {
+	for i := 0; i < len(key); i++ {
+		child, found := node.children[key[i]]
+		if !found {
+			child = newTrieNode()
+			node.children[key[i]] = child
+		}
+		node = child
+	}
+	node.refs = append(node.refs, ref)
+}
+
+func NewPatternSet(caseInsensitive bool) *PatternSet {
+	return &PatternSet{
+		caseInsensitive: caseInsensitive,
+		exact:           make(map[string][]patternRef),
+		prefixes:        newTrieNode(),
+		suffixes:        newTrieNode(),
+		cache:           make(map[string][]int),
+	}
+}
+
+// CompilePatterns compiles a single pattern list into its own PatternSet.
+func CompilePatterns(patterns *Patterns, caseInsensitive bool) *CompiledPatterns {
+	return NewPatternSet(caseInsensitive).Add(patterns)
+}
+
+// Add compiles a pattern list into the set and returns a handle for matching against it.
+func (set *PatternSet) Add(patterns *Patterns) *CompiledPatterns {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	id := set.lists
+	set.lists++
+	set.cache = make(map[string][]int)
+	for _, p := range *patterns {
+		set.addPattern(id, p)
+	}
+	return &CompiledPatterns{set: set, id: id}
+}
+
+func (set *PatternSet) addPattern(id int, p Pattern) {
+	base := p.Base()
+	ref := patternRef{list: id, exclude: p.IsExclusion(), specificity: p.Specificity(), pattern: base}
+	s := string(base)
+	if base.IsRegex() || s == "" {
+		set.general = append(set.general, ref)
+		return
+	}
+	if set.caseInsensitive {
+		s = strings.ToUpper(s)
+	}
+	inner := strings.Trim(s, "*")
+	if strings.ContainsAny(inner, "*?[\\") {
+		set.general = append(set.general, ref)
+		return
+	}
+	ref.literal = inner
+	leading := strings.HasPrefix(s, "*")
+	trailing := strings.HasSuffix(s, "*") && len(s) > 1
+	switch {
+	case inner == "":
+		set.prefixes.insert("", ref)
+	case leading && trailing:
+		set.contains = append(set.contains, ref)
+	case trailing:
+		set.prefixes.insert(inner, ref)
+	case leading:
+		set.suffixes.insert(reverse(inner), ref)
+	default:
+		set.exact[inner] = append(set.exact[inner], ref)
+	}
+}
+
+// Match returns, for every list in the set, the best Specificity of the patterns matching
+// name or -1 if no pattern matched or an exclusion did.
+func (set *PatternSet) Match(name string) []int {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if result, found := set.cache[name]; found {
+		return result
+	}
+
+	best := make([]int, set.lists)
+	for i := range best {
+		best[i] = -1
+	}
+	excluded := make([]bool, set.lists)
+	visit := func(refs []patternRef) {
+		for _, ref := range refs {
+			if ref.exclude {
+				excluded[ref.list] = true
+			} else if ref.specificity > best[ref.list] {
+				best[ref.list] = ref.specificity
+			}
+		}
+	}
+
+	s := name
+	if set.caseInsensitive {
+		s = strings.ToUpper(s)
+	}
+	visit(set.exact[s])
+	node := set.prefixes
+	visit(node.refs)
+	for i := 0; i < len(s) && node != nil; i++ {
+		node = node.children[s[i]]
+		if node != nil {
+			visit(node.refs)
+		}
+	}
+	node = set.suffixes
+	for i := len(s) - 1; i >= 0 && node != nil; i-- {
+		node = node.children[s[i]]
+		if node != nil {
+			visit(node.refs)
+		}
+	}
+	for _, ref := range set.contains {
+		if strings.Contains(s, ref.literal) {
+			visit([]patternRef{ref})
+		}
+	}
+	for _, ref := range set.general {
+		if Match(name, ref.pattern, set.caseInsensitive) {
+			visit([]patternRef{ref})
+		}
+	}
+
+	for i := range best {
+		if excluded[i] {
+			best[i] = -1
+		}
+	}
+	set.cache[name] = best
+	return best
+}
+
+// MatchAny returns true if name matches any of the patterns and none of the "!" exclusions.
+func (compiled *CompiledPatterns) MatchAny(name string) bool {
+	_, matched := compiled.MatchBest(name)
+	return matched
+}
+
+// MatchBest works like MatchAny but also returns the highest Specificity of the matching patterns.
+func (compiled *CompiledPatterns) MatchBest(name string) (int, bool) {
+	specificity := compiled.set.Match(name)[compiled.id]
+	return specificity, specificity >= 0
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
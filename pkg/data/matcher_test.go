@@ -0,0 +1,141 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+)
+
+// benchmarkGroups resembles the default configuration, including the long hidden groups.
+var benchmarkGroups = [][2]string{
+	{"basic", "PATH"},
+	{"aws", "AWS_*, EC2_*"},
+	{"cloud", "KUBECONFIG, KUBE_*, DOCKER_*, COMPOSE_*, TF_*, TERRAFORM_*"},
+	{"dev", "GOROOT, GOPATH, GOBIN, JAVA_HOME, JAVA_OPTS, JDK_HOME, CLASSPATH, MAVEN_HOME, GRADLE_HOME, VIRTUAL_ENV, PYTHONPATH, PYTHONHOME, CONDA_*, PYENV_*, NODE_*, NPM_*, NVM_*, CARGO_HOME, RUSTUP_HOME, GEM_HOME, RBENV_*, BUNDLE_*"},
+	{"git", "GIT_*"},
+	{".shell", "BASH_*, BASH, BASHPID, BASHOPTS, BASH_ENV, COMP_WORDBREAKS, DIRSTACK, EPOCHREALTIME, EPOCHSECONDS, FUNCNAME, GROUPS, HISTCMD, LINENO, MACHTYPE, OPTARG, OPTIND, OSTYPE, PIPESTATUS, SHELLOPTS, SHLVL, ZSH_*, ZSH, ZSH_NAME, ZSH_VERSION, ZDOTDIR, ZLE_*, RPROMPT, RPS1, PROMPT, PROMPT2, PROMPT3, PROMPT4, PROMPT_EOL_MARK, PSVAR, PS*, SECONDS, RANDOM, _, COLUMNS, LINES, TTY, HIST*, SAVEHIST, MAIL, MAILCHECK, UID, EUID, PPID"},
+	{".locale", "LANG, LANGUAGE, LC_*, LINGUAS"},
+	{".network", "SSH_*, TMUX, TMUX_*, STY, WINDOW"},
+	{".system", "XDG_*, SUDO_*, COMMAND_MODE, DISPLAY, MOTD_SHOWN, PULSE_SERVER, WAYLAND_DISPLAY, SECURITYSESSIONID, LOGNAME, NAME, USER, TMP, TMPDIR, HOME, EDITOR, SHELL, INFOPATH, HOMEBREW_*"},
+	{".terminal", "TERM, TERM_PROGRAM, TERM_PROGRAM_VERSION, TERMCAP, TERMINFO_*, TERM_FEATURES, COLORTERM, COLORFGBG, LSCOLORS, LS_COLORS, LESS, LESSCLOSE, LESSOPEN, PAGER"},
+	{".editors", "VSCODE_*, VSCODE_GIT_ASKPASS_*, GIT_ASKPASS"},
+	{".windows", "TEMP, USERNAME, USERPROFILE, USERDOMAIN*, OS, LOGONSERVER, COMPUTERNAME, HOMEDRIVE, HOMEPATH, PUBLIC, APPDATA, LOCALAPPDATA, PROGRAMDATA, PROGRAMFILES, PROGRAMFILES(X86), PROGRAMW6432, COMMONPROGRAMFILES, COMMONPROGRAMFILES(X86), COMMONPROGRAMW6432, DRIVERDATA, SYSTEMDRIVE, SYSTEMROOT, WINDIR, NUMBER_OF_PROCESSORS, PROCESSOR_*, ALLUSERSPROFILE, PSMODULEPATH, FP_NO_HOST_CHECK, PATHEXT, OneDrive*, COMSPEC, CMDCMDLINE, CMDEXTVERSION, ERRORLEVEL, SESSIONNAME, CLIENTNAME, WT_*, HOSTTYPE, WSLENV, WSL_DISTRO_NAME, WSL_*, WSL2_*"},
+	{"..ignore", "_, PWD, OLDPWD, SHLVL"},
+	{"custom", "*_TOKEN, *SPARK*, re:HADOOP_[A-Z]+_HOME, KUBE?_*, !AWS_PROFILE"},
+}
+
+func newBenchmarkGroups(caseInsensitive bool) *Groups {
+	g := NewGroups()
+	for _, group := range benchmarkGroups {
+		g.ParseAndAdd(group[0], group[1], caseInsensitive)
+	}
+	return g
+}
+
+// benchmarkEnvs returns a CI runner sized environment.
+func benchmarkEnvs() Envs {
+	envs := Envs{"PATH", "HOME", "AWS_PROFILE", "GITHUB_TOKEN", "HADOOP_CONF_HOME", "KUBE1_CONFIG", "LC_ALL", "ProgramFiles(x86)"}
+	prefixes := []string{"AWS", "RUNNER", "GITHUB", "INPUT", "JAVA_HOME_8", "STATE", "ACTIONS", "XDG", "CI", "BUILD"}
+	for i := 0; len(envs) < 600; i++ {
+		envs = append(envs, fmt.Sprintf("%s_VAR_%d", prefixes[i%len(prefixes)], i))
+	}
+	return envs
+}
+
+func TestPatternSetMatchesMatchBest(t *testing.T) {
+	for _, caseInsensitive := range []bool{false, true} {
+		set := NewPatternSet(caseInsensitive)
+		lists := make([]*Patterns, 0, len(benchmarkGroups))
+		compiled := make([]*CompiledPatterns, 0, len(benchmarkGroups))
+		for _, group := range benchmarkGroups {
+			patterns := ParsePatterns(group[1], caseInsensitive)
+			lists = append(lists, patterns)
+			compiled = append(compiled, set.Add(patterns))
+		}
+		for _, env := range append(benchmarkEnvs(), "A", "", "PS1", "xdg_config", "MY_SPARK_HOME") {
+			for i := range lists {
+				expected, expectedOk := MatchBest(env, lists[i], caseInsensitive)
+				actual, actualOk := compiled[i].MatchBest(env)
+				if expected != actual || expectedOk != actualOk {
+					t.Errorf("Mismatch for %q in %s (case insensitive %v): %d/%v != %d/%v",
+						env, benchmarkGroups[i][0], caseInsensitive, actual, actualOk, expected, expectedOk)
+				}
+			}
+		}
+	}
+}
+
+func TestPatternSetCache(t *testing.T) {
+	set := NewPatternSet(false)
+	foo := set.Add(ParsePatterns("FOO*", false))
+	if !foo.MatchAny("FOOBAR") {
+		t.Error("FOOBAR should match")
+	}
+	// Adding a list must invalidate cached results
+	bar := set.Add(ParsePatterns("*BAR", false))
+	if !bar.MatchAny("FOOBAR") || !foo.MatchAny("FOOBAR") {
+		t.Error("FOOBAR should match both lists")
+	}
+}
+
+func TestGroupsShareCompiledSet(t *testing.T) {
+	set := NewPatternSet(false)
+	g := newBenchmarkGroups(false)
+	g.Compile(set)
+	passwords := set.Add(ParsePatterns("*_TOKEN", false))
+	m, _ := g.MatchAll(Envs{"GITHUB_TOKEN", "AWS_PROFILE"}, false)
+	if len(m["custom"]) != 1 || m["custom"][0] != "GITHUB_TOKEN" {
+		t.Errorf("Expected GITHUB_TOKEN in custom: %v", m)
+	}
+	if !passwords.MatchAny("GITHUB_TOKEN") {
+		t.Error("Password patterns should match from the shared set")
+	}
+	if !g.IsIgnored("PWD", false) || g.IsIgnored("PATH", false) {
+		t.Error("IsIgnored mismatch")
+	}
+	// Modifying the groups recompiles them
+	g.ParseAndAdd("late", "LATE_*", false)
+	m, _ = g.MatchAll(Envs{"LATE_ONE"}, false)
+	if len(m["late"]) != 1 {
+		t.Errorf("Expected late group after recompile: %v", m)
+	}
+}
+
+// matchAllUncompiled is the previous MatchAll implementation, kept as a benchmark baseline.
+func matchAllUncompiled(groups *Groups, envs Envs, caseInsensitive bool) (GroupNameToEnvs, Envs) {
+	result := make(GroupNameToEnvs)
+	unmatched := make(Envs, 0)
+	for _, env := range envs {
+		matched := false
+		for _, group := range groups.GetAllNames() {
+			patterns, _ := groups.GetPatterns(group)
+			if MatchAny(env, patterns, caseInsensitive) {
+				matched = true
+				result[group] = append(result[group], env)
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, env)
+		}
+	}
+	return result, unmatched
+}
+
+func BenchmarkMatchAllUncompiled(b *testing.B) {
+	g := newBenchmarkGroups(true)
+	envs := benchmarkEnvs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matchAllUncompiled(g, envs, true)
+	}
+}
+
+func BenchmarkMatchAllCompiled(b *testing.B) {
+	g := newBenchmarkGroups(true)
+	envs := benchmarkEnvs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// A fresh set per iteration so the per-name cache does not hide the matching cost
+		g.Compile(NewPatternSet(true))
+		g.MatchAll(envs, true)
+	}
+}
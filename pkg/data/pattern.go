@@ -1,19 +1,37 @@
 package data
 
 import (
+	"fmt"
+	"regexp"
 	"strings"
+	"sync"
 )
 
+// RegexPrefix marks a pattern as a regular expression instead of a glob.
+const RegexPrefix = "re:"
+
+// ExcludePrefix marks a pattern as an exclusion, names matching it never match the list.
+const ExcludePrefix = "!"
+
 type Pattern string
 type Patterns []Pattern
 
+// regexCache holds compiled regular expressions keyed by pattern and case sensitivity.
+var regexCache sync.Map
+
+type regexKey struct {
+	pattern         Pattern
+	caseInsensitive bool
+}
+
 // ParsePatterns parses a string with patterns
 func ParsePatterns(s string, caseInsensitive bool) *Patterns {
 	patterns := make(Patterns, 0, 8)
 	for _, p := range strings.Split(s, ",") {
 		trimmed := strings.TrimSpace(p)
 		if len(trimmed) > 0 {
-			if caseInsensitive {
+			// Regular expressions are matched with (?i) instead, uppercasing would change their meaning.
+			if caseInsensitive && !Pattern(trimmed).IsRegex() {
 				trimmed = strings.ToUpper(trimmed)
 			}
 			patterns = append(patterns, Pattern(trimmed))
@@ -22,18 +40,135 @@ func ParsePatterns(s string, caseInsensitive bool) *Patterns {
 	return &patterns
 }
 
-// MatchAny Match any of the patterns
+// Validate returns an error describing the first pattern that can not be used.
+func (patterns *Patterns) Validate() error {
+	for _, p := range *patterns {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate returns an error if the glob or regular expression is malformed.
+func (p Pattern) Validate() error {
+	if p.IsExclusion() {
+		if p.Base() == "" {
+			return fmt.Errorf("empty exclusion %q", string(p))
+		}
+		return p.Base().Validate()
+	}
+	if p.IsRegex() {
+		if _, err := compileRegex(p, false); err != nil {
+			return fmt.Errorf("invalid regex %q: %v", string(p), err)
+		}
+		return nil
+	}
+	if !validGlob([]rune(string(p))) {
+		return fmt.Errorf("invalid glob %q", string(p))
+	}
+	return nil
+}
+
+// IsRegex returns true if the pattern (ignoring any "!") uses the "re:" prefix.
+func (p Pattern) IsRegex() bool {
+	return strings.HasPrefix(string(p.Base()), RegexPrefix)
+}
+
+// IsExclusion returns true if the pattern uses the "!" prefix.
+func (p Pattern) IsExclusion() bool {
+	return strings.HasPrefix(string(p), ExcludePrefix)
+}
+
+// Base returns the pattern without the "!" prefix.
+func (p Pattern) Base() Pattern {
+	return Pattern(strings.TrimSpace(strings.TrimPrefix(string(p), ExcludePrefix)))
+}
+
+// Specificity scores how specific a pattern is: four points per literal character (for regular
+// expressions only the literal prefix counts), two more if the start is anchored and one more
+// for patterns without wildcards.
+func (p Pattern) Specificity() int {
+	base := p.Base()
+	if base.IsRegex() {
+		re, err := regexp.Compile(strings.TrimPrefix(string(base), RegexPrefix))
+		if err != nil {
+			return 0
+		}
+		prefix, complete := re.LiteralPrefix()
+		return specificityScore(len(prefix), len(prefix) > 0, complete)
+	}
+	literals := 0
+	exact := true
+	pattern := []rune(string(base))
+	anchored := len(pattern) > 0 && pattern[0] != '*'
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?':
+			exact = false
+		case '[':
+			exact = false
+			if _, width := matchClass(pattern[i:], 0); width > 0 {
+				i += width - 1
+			}
+		case '\\':
+			i++
+			literals++
+		default:
+			literals++
+		}
+	}
+	return specificityScore(literals, anchored, exact)
+}
+
+func specificityScore(literals int, anchored, exact bool) int {
+	score := literals * 4
+	if anchored {
+		score += 2
+	}
+	if exact {
+		score++
+	}
+	return score
+}
+
+// MatchAny returns true if s matches any of the patterns and none of the "!" exclusions.
 func MatchAny(s string, patterns *Patterns, caseInsensitive bool) bool {
+	_, matched := MatchBest(s, patterns, caseInsensitive)
+	return matched
+}
+
+// MatchBest works like MatchAny but also returns the highest Specificity of the matching patterns.
+func MatchBest(s string, patterns *Patterns, caseInsensitive bool) (int, bool) {
+	best := -1
 	for _, p := range *patterns {
-		if Match(s, p, caseInsensitive) {
-			return true
+		if p.IsExclusion() {
+			if Match(s, p.Base(), caseInsensitive) {
+				return -1, false
+			}
+		} else if Match(s, p, caseInsensitive) {
+			if specificity := p.Specificity(); specificity > best {
+				best = specificity
+			}
 		}
 	}
-	return false
+	return best, best >= 0
 }
 
-// Match Simple glob macher where pattern can be PATTERN, *PATTERN, *PATTERN* or PATTERN*
+// Match matches s against a glob (supporting *, ? and [...] classes) or, with the "re:"
+// prefix, a regular expression. Both must match the whole name. A "!" exclusion only has a
+// meaning in a list (see MatchAny) and never matches on its own.
 func Match(s string, p Pattern, caseInsensitive bool) bool {
+	if p.IsExclusion() {
+		return false
+	}
+	if p.IsRegex() {
+		re, err := compileRegex(p, caseInsensitive)
+		if err != nil {
+			return false
+		}
+		return re.MatchString(s)
+	}
 	pattern := string(p)
 	if caseInsensitive {
 		s = strings.ToUpper(s)
@@ -43,23 +178,136 @@ func Match(s string, p Pattern, caseInsensitive bool) bool {
 	} else if pattern == "*" {
 		return true
 	}
-	first_char := pattern[0]
-	last_char_pos := len(pattern) - 1
-	last_char := pattern[last_char_pos]
-	if first_char == '*' && last_char == '*' {
-		if strings.Contains(s, pattern[1:last_char_pos]) {
-			return true
+	return matchGlob([]rune(pattern), []rune(s))
+}
+
+func compileRegex(p Pattern, caseInsensitive bool) (*regexp.Regexp, error) {
+	key := regexKey{pattern: p, caseInsensitive: caseInsensitive}
+	if re, found := regexCache.Load(key); found {
+		return re.(*regexp.Regexp), nil
+	}
+	expr := "^(?:" + strings.TrimPrefix(string(p), RegexPrefix) + ")$"
+	if caseInsensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(key, re)
+	return re, nil
+}
+
+// matchGlob matches the whole of s against pattern, backtracking to the last * on mismatch.
+func matchGlob(pattern, s []rune) bool {
+	px, sx := 0, 0
+	nextPx, nextSx := 0, 0
+	for px < len(pattern) || sx < len(s) {
+		if px < len(pattern) {
+			switch c := pattern[px]; c {
+			case '*':
+				nextPx, nextSx = px, sx+1
+				px++
+				continue
+			case '?':
+				if sx < len(s) {
+					px++
+					sx++
+					continue
+				}
+			case '[':
+				if sx < len(s) {
+					if matched, width := matchClass(pattern[px:], s[sx]); matched {
+						px += width
+						sx++
+						continue
+					}
+				}
+			case '\\':
+				if px+1 < len(pattern) && sx < len(s) && pattern[px+1] == s[sx] {
+					px += 2
+					sx++
+					continue
+				}
+			default:
+				if sx < len(s) && s[sx] == c {
+					px++
+					sx++
+					continue
+				}
+			}
 		}
-	} else if last_char == '*' {
-		if strings.HasPrefix(s, pattern[0:last_char_pos]) {
-			return true
+		if 0 < nextSx && nextSx <= len(s) {
+			px, sx = nextPx, nextSx
+			continue
 		}
-	} else if first_char == '*' {
-		if strings.HasSuffix(s, pattern[1:last_char_pos+1]) {
-			return true
+		return false
+	}
+	return true
+}
+
+// matchClass matches r against the [...] class at the start of pattern.
+// Returns whether it matched and the width of the class, width is 0 if the class is malformed.
+func matchClass(pattern []rune, r rune) (bool, int) {
+	i := 1
+	negate := false
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negate = true
+		i++
+	}
+	matched := false
+	first := true
+	for i < len(pattern) {
+		if pattern[i] == ']' && !first {
+			return matched != negate, i + 1
+		}
+		first = false
+		lo, width := classChar(pattern[i:])
+		if width == 0 {
+			return false, 0
+		}
+		i += width
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			hi, width = classChar(pattern[i+1:])
+			if width == 0 {
+				return false, 0
+			}
+			i += 1 + width
+		}
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+	return false, 0
+}
+
+// classChar reads a possibly escaped character inside a class.
+func classChar(pattern []rune) (rune, int) {
+	if pattern[0] == '\\' {
+		if len(pattern) < 2 {
+			return 0, 0
+		}
+		return pattern[1], 2
+	}
+	return pattern[0], 1
+}
+
+func validGlob(pattern []rune) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '[':
+			_, width := matchClass(pattern[i:], 0)
+			if width == 0 {
+				return false
+			}
+			i += width - 1
+		case '\\':
+			if i+1 >= len(pattern) {
+				return false
+			}
+			i++
 		}
-	} else if s == pattern {
-		return true
 	}
-	return false
+	return true
 }
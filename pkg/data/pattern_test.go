@@ -102,3 +102,101 @@ func TestMatchCaseInsensitive(t *testing.T) {
 		t.Error("Case insensitive prefix match should work")
 	}
 }
+
+func TestMatchGlobAnywhere(t *testing.T) {
+	tests := []struct {
+		s       string
+		pattern Pattern
+		match   bool
+	}{
+		{"AWS_SECRET_KEY", "AWS_*_KEY", true},
+		{"AWS_KEY", "AWS_*_KEY", false},
+		{"AWS_SECRET_KEYS", "AWS_*_KEY", false},
+		{"KUBE1_CONFIG", "KUBE?_*", true},
+		{"KUBE_CONFIG", "KUBE?_*", false},
+		{"KUBE12_CONFIG", "KUBE?_*", false},
+		{"LC_ALL", "LC_[A-Z]*", true},
+		{"LC_1", "LC_[A-Z]*", false},
+		{"LC_1", "LC_[!A-Z]", true},
+		{"LC_1", "LC_[^0-9]", false},
+		{"X]", "X[]]", true},
+		{"A*B", "A\\*B", true},
+		{"AXB", "A\\*B", false},
+		{"PROGRAMFILES(X86)", "PROGRAMFILES(X86)", true},
+		{"A_B_C", "*_*_*", true},
+		{"", "*", true},
+	}
+	for _, tt := range tests {
+		if Match(tt.s, tt.pattern, false) != tt.match {
+			t.Errorf("Match(%q, %q) should be %v", tt.s, tt.pattern, tt.match)
+		}
+	}
+}
+
+func TestMatchRegex(t *testing.T) {
+	if !Match("AWS_ACCESS_KEY_ID", "re:AWS_(ACCESS|SECRET)_.*", false) {
+		t.Error("Regex should match")
+	}
+	if Match("MY_AWS_ACCESS_KEY_ID", "re:AWS_(ACCESS|SECRET)_.*", false) {
+		t.Error("Regex should match the whole name")
+	}
+	if Match("aws_access", "re:AWS_ACCESS", false) {
+		t.Error("Regex should be case sensitive")
+	}
+	p := ParsePatterns("re:AWS_\\d+", true)
+	if (*p)[0] != Pattern("re:AWS_\\d+") {
+		t.Errorf("Regex should not be uppercased: %s", (*p)[0])
+	}
+	if !MatchAny("aws_42", p, true) {
+		t.Error("Case insensitive regex should match")
+	}
+	if MatchAny("aws_x", p, true) {
+		t.Error("\\d should not be turned into \\D")
+	}
+}
+
+func TestValidatePatterns(t *testing.T) {
+	if err := ParsePatterns("FOO, *BAR*, [A-Z]?, re:^X.*$", false).Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, invalid := range []string{"FOO[", "[A-", "FOO\\", "re:(", "re:X[z-a]"} {
+		if err := ParsePatterns(invalid, false).Validate(); err == nil {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestMatchExclusion(t *testing.T) {
+	p := ParsePatterns("AWS_*, !AWS_PROFILE, !re:AWS_.*_ID", false)
+	if !MatchAny("AWS_REGION", p, false) {
+		t.Error("AWS_REGION should match")
+	}
+	if MatchAny("AWS_PROFILE", p, false) || MatchAny("AWS_KEY_ID", p, false) {
+		t.Error("Excluded names should not match")
+	}
+	if MatchAny("OTHER", ParsePatterns("!FOO", false), false) {
+		t.Error("Exclusions alone should not match anything")
+	}
+	if Match("OTHER", "!FOO", false) || Match("FOO", "!FOO", false) {
+		t.Error("A single exclusion pattern should not match anything")
+	}
+	ci := ParsePatterns("aws_*, !aws_profile", true)
+	if MatchAny("Aws_Profile", ci, true) || !MatchAny("aws_region", ci, true) {
+		t.Error("Case insensitive exclusion failed")
+	}
+	if err := ParsePatterns("FOO, !", false).Validate(); err == nil {
+		t.Error("Empty exclusion should be invalid")
+	}
+}
+
+func TestSpecificity(t *testing.T) {
+	ordered := []Pattern{"*", "*FOO*", "FOO*", "FOO_*", "FOOBAR"}
+	for i := 1; i < len(ordered); i++ {
+		if ordered[i-1].Specificity() >= ordered[i].Specificity() {
+			t.Errorf("%s should be less specific than %s", ordered[i-1], ordered[i])
+		}
+	}
+	if Pattern("re:FOO.*").Specificity() != Pattern("FOO*").Specificity() {
+		t.Error("Regex literal prefix should count like glob literals")
+	}
+}
@@ -236,6 +236,45 @@ func (profile *Profile) IsMerged(p *Profile) bool {
 	return true
 }
 
+// Unmerge reverts the changes made by merging p: variables it sets are unset and path
+// components it prepends or appends are removed. Variables p unsets are left alone since
+// their previous value is not known.
+func (profile *Profile) Unmerge(p *Profile) {
+	sep := string(os.PathListSeparator)
+	for k, v := range p.env {
+		if _, exists := profile.Get(k); !exists {
+			continue
+		}
+		switch p.GetMergeMode(k) {
+		case MergePrepend, MergeAppend:
+			existing, _ := profile.Get(k)
+			remaining := removePathComponents(existing, v, sep)
+			if remaining == "" {
+				profile.SetNil(k)
+			} else {
+				profile.Set(k, remaining)
+			}
+		default:
+			profile.SetNil(k)
+		}
+	}
+}
+
+// removePathComponents removes all components of removal from existing.
+func removePathComponents(existing, removal, sep string) string {
+	removeSet := make(map[string]bool)
+	for _, p := range splitPath(removal, sep) {
+		removeSet[p] = true
+	}
+	result := make([]string, 0)
+	for _, p := range splitPath(existing, sep) {
+		if !removeSet[p] {
+			result = append(result, p)
+		}
+	}
+	return strings.Join(result, sep)
+}
+
 // pathContainsAll returns true if all components of required are present in current.
 func pathContainsAll(current, required, sep string) bool {
 	currentParts := splitPath(current, sep)
@@ -286,6 +325,16 @@ func (profile *Profile) MergeStrings(envList []string) {
 	}
 }
 
+// Environ returns the variables as NAME=value entries sorted by name, like os.Environ.
+func (profile *Profile) Environ() []string {
+	names := profile.SortedNames(false)
+	env := make([]string, len(names))
+	for i, name := range names {
+		env[i] = name + "=" + profile.env[name]
+	}
+	return env
+}
+
 func (profile *Profile) String() string {
 	return strings.Join(profile.SortedNames(true), ",")
 }
@@ -78,6 +78,14 @@ func TestMergeStrings(t *testing.T) {
 	verifyNil(t, p, "REMOVE", true)
 }
 
+func TestEnviron(t *testing.T) {
+	p := NewProfile(false)
+	p.MergeStrings([]string{"FOO=2", "BAR=FOO=FOOBAR", "EMPTY=", "REMOVE"})
+	if actual := strings.Join(p.Environ(), ","); actual != "BAR=FOO=FOOBAR,EMPTY=,FOO=2" {
+		t.Errorf("Unexpected environment: %s", actual)
+	}
+}
+
 func checkInList(t *testing.T, theList []string, value string) {
 	for _, item := range theList {
 		if value == item {
@@ -286,3 +294,26 @@ func TestCaseInsensitive(t *testing.T) {
 	verifyNil(t, p, "goodbye", true)
 	verifyNil(t, p, "GOODBYE", true)
 }
+
+func TestUnmerge(t *testing.T) {
+	env := NewProfile(false)
+	env.Set("PATH", p("/usr/bin", "/bin"))
+	env.Set("KEEP", "yes")
+
+	profile := NewProfile(false)
+	profile.SetWithMode("PATH", "/home/user/venv/bin", MergePrepend)
+	profile.SetWithMode("ONLY", "/opt/only", MergeAppend)
+	profile.Set("VIRTUAL_ENV", "/home/user/venv")
+	profile.SetNil("KEEP")
+
+	env.Merge(profile)
+	env.Set("KEEP", "yes")
+	env.Unmerge(profile)
+	verifyValue(t, env, "PATH", p("/usr/bin", "/bin"))
+	verifyValue(t, env, "KEEP", "yes")
+	verifyNil(t, env, "VIRTUAL_ENV", true)
+	verifyNil(t, env, "ONLY", true)
+	if env.IsMerged(profile) {
+		t.Error("Profile should no longer be merged")
+	}
+}
@@ -0,0 +1,45 @@
+package data
+
+import (
+	"sort"
+	"strings"
+)
+
+// GroupSuggestion is a proposed group for variables sharing a common prefix.
+type GroupSuggestion struct {
+	Name    string // Suggested group name (lowercase prefix)
+	Pattern string // Suggested pattern, e.g. SPARK_*
+	Envs    Envs   // Variables the pattern would match
+}
+
+// SuggestGroups clusters variables by the prefix before their first "_" and returns a
+// suggestion for each prefix shared by at least minCount variables, largest first.
+func SuggestGroups(envs Envs, minCount int) []GroupSuggestion {
+	clusters := make(map[string]Envs)
+	for _, env := range envs {
+		prefix, _, found := strings.Cut(env, "_")
+		if !found || prefix == "" {
+			continue
+		}
+		clusters[prefix] = append(clusters[prefix], env)
+	}
+	suggestions := make([]GroupSuggestion, 0, len(clusters))
+	for prefix, members := range clusters {
+		if len(members) < minCount {
+			continue
+		}
+		sort.Strings(members)
+		suggestions = append(suggestions, GroupSuggestion{
+			Name:    strings.ToLower(prefix),
+			Pattern: prefix + "_*",
+			Envs:    members,
+		})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if len(suggestions[i].Envs) != len(suggestions[j].Envs) {
+			return len(suggestions[i].Envs) > len(suggestions[j].Envs)
+		}
+		return suggestions[i].Name < suggestions[j].Name
+	})
+	return suggestions
+}
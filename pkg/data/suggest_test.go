@@ -0,0 +1,22 @@
+package data
+
+import (
+	"testing"
+)
+
+func TestSuggestGroups(t *testing.T) {
+	envs := Envs{"SPARK_HOME", "HADOOP_HOME", "SPARK_CONF_DIR", "HADOOP_CONF_DIR", "SPARK_MASTER", "LONELY_ONE", "_PRIVATE", "NOUNDERSCORE"}
+	suggestions := SuggestGroups(envs, 2)
+	if len(suggestions) != 2 {
+		t.Fatalf("Expected 2 suggestions, got %v", suggestions)
+	}
+	if suggestions[0].Name != "spark" || suggestions[0].Pattern != "SPARK_*" || len(suggestions[0].Envs) != 3 {
+		t.Errorf("Unexpected first suggestion: %+v", suggestions[0])
+	}
+	if suggestions[1].Name != "hadoop" || suggestions[1].Envs[0] != "HADOOP_CONF_DIR" {
+		t.Errorf("Unexpected second suggestion: %+v", suggestions[1])
+	}
+	if len(SuggestGroups(envs, 4)) != 0 {
+		t.Error("Expected no suggestions with min count 4")
+	}
+}
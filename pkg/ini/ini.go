@@ -29,6 +29,7 @@ type Variable struct {
 
 type Section struct {
 	variables map[string]Variable
+	order     []string // Variable names in the order they first appeared
 }
 
 // Duplicate records a variable that appeared more than once in a section.
@@ -74,6 +75,9 @@ func NewIni(path string) (*IniFile, error) {
 		}
 		if _, exists := section.variables[varName]; exists {
 			ini.Duplicates = append(ini.Duplicates, Duplicate{Section: sectionName, Variable: varName})
+		} else {
+			section.order = append(section.order, varName)
+			ini.sections[sectionName] = section
 		}
 		section.variables[varName] = Variable{varType: varType, value: varValue, Operator: operator}
 	}
@@ -202,3 +206,14 @@ func (iniFile *IniFile) GetAllVariables(section string) []string {
 	sort.Strings(variables)
 	return variables
 }
+
+// GetVariablesInOrder Get list of all variables in the order they were declared
+func (iniFile *IniFile) GetVariablesInOrder(section string) []string {
+	s, ok := iniFile.sections[section]
+	if ! ok {
+		return []string{}
+	}
+	variables := make([]string, len(s.order))
+	copy(variables, s.order)
+	return variables
+}
@@ -221,3 +221,27 @@ func TestOperators(t *testing.T) {
 		t.Errorf("Expected OpReplace for PATH=, got %d", op)
 	}
 }
+
+func TestVariablesInOrder(t *testing.T) {
+	file, err := ioutil.TempFile("", "config")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(testConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ini, err := NewIni(file.Name())
+	if err != nil {
+		t.Error("Failed to read configuration")
+	}
+	order := ini.GetVariablesInOrder("groups")
+	if len(order) != 3 || order[0] != "one" || order[1] != "two" || order[2] != "added" {
+		t.Errorf("Unexpected declaration order: %v", order)
+	}
+	if len(ini.GetVariablesInOrder("missing")) != 0 {
+		t.Error("Missing section should have no variables")
+	}
+}
@@ -18,10 +18,14 @@ type ColorPrintFunc func(format string, a ...interface{}) string
 
 type Output struct {
 	replacePathTilde string
-	paths            data.Patterns
-	passwords        data.Patterns
+	paths            *data.CompiledPatterns
+	passwords        *data.CompiledPatterns
 	displayRaw       bool
-	caseInsensitive  bool
+	reveal           bool
+	tableWidth       int
+	tableWide        bool
+	detectSecrets    bool
+	maskStyle        string
 	diffNames        map[string]bool
 
 	groupSprintf   ColorPrintFunc
@@ -31,14 +35,13 @@ type Output struct {
 	diffSprintf    ColorPrintFunc
 }
 
-func NewOutput(replacePathTilde string, paths, passwords data.Patterns, displayRaw bool, caseInsensitive bool,
+func NewOutput(replacePathTilde string, paths, passwords *data.CompiledPatterns, displayRaw bool,
 	groupColor, profileColor, envNameColor, pathColor, diffColor string) *Output {
 	return &Output{
 		replacePathTilde: replacePathTilde,
 		paths:            paths,
 		passwords:        passwords,
 		displayRaw:       displayRaw,
-		caseInsensitive:  caseInsensitive,
 		groupSprintf:     color.New(mapColorDefault(groupColor, "magenta")).SprintfFunc(),
 		profileSprintf:   color.New(mapColorDefault(profileColor, "green")).SprintfFunc(),
 		envNameSprintf:   color.New(mapColorDefault(envNameColor, "cyan")).SprintfFunc(),
@@ -47,9 +50,25 @@ func NewOutput(replacePathTilde string, paths, passwords data.Patterns, displayR
 	}
 }
 
-// Printf output shown to end user - all output goes to stderr
+// toStdout is set when shell commands are not written to stdout, so it is free for the user.
+var toStdout bool
+
+// UseStdout selects stdout instead of stderr for output shown to the end user.
+func UseStdout(enabled bool) {
+	toStdout = enabled
+}
+
+// Writer returns the file output shown to the end user goes to, stderr unless UseStdout was enabled.
+func Writer() *os.File {
+	if toStdout {
+		return os.Stdout
+	}
+	return os.Stderr
+}
+
+// Printf output shown to end user - goes to stderr unless UseStdout was enabled
 func Printf(format string, a ...interface{}) {
-	_, err := fmt.Fprintf(os.Stderr, format, a...)
+	_, err := fmt.Fprintf(Writer(), format, a...)
 	if err != nil {
 		panic("Failed to output string")
 	}
@@ -132,7 +151,7 @@ func (out *Output) SetDiffNames(names map[string]bool) {
 
 // IsPathVariable returns true if the variable name matches the configured path patterns.
 func (out *Output) IsPathVariable(name string) bool {
-	return data.MatchAny(name, &out.paths, out.caseInsensitive)
+	return out.paths.MatchAny(name)
 }
 
 // ReplaceHomeTilde replaces the home directory prefix with ~ if configured.
@@ -147,16 +166,17 @@ func (out *Output) SprintEnv(sh *shell.Shell, name, value string) string {
 	outputName := name
 	outputValue := value
 	if out.displayRaw {
-		outputValue = sh.Escape(value)
+		masked, _ := out.MaskValue(name, value)
+		outputValue = sh.Escape(masked)
 	} else {
 		if out.diffNames != nil && out.diffNames[name] {
 			outputName = out.DiffSprintf("%s", name)
 		} else {
 			outputName = out.EnvNameSprintf("%s", name)
 		}
-		if data.MatchAny(name, &out.passwords, out.caseInsensitive) {
-			outputValue = "****--->hidden<---****"
-		} else if data.MatchAny(name, &out.paths, out.caseInsensitive) {
+		if masked, ok := out.MaskValue(name, value); ok {
+			outputValue = masked
+		} else if out.paths.MatchAny(name) {
 			sections := strings.Split(value, pathListSeparator)
 			for i := range sections {
 				if len(out.replacePathTilde) > 0 {
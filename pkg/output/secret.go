@@ -0,0 +1,153 @@
+package output
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Supported values for the mask_style setting.
+const (
+	MaskStyleFull    = "full"    // Replace the whole value
+	MaskStylePartial = "partial" // Show the last four characters
+)
+
+const (
+	partialMaskPrefix  = "****"
+	partialVisibleSize = 4
+	partialMinSize     = 12 // Shorter values are masked in full
+	minEntropySize     = 20
+	minEntropyBits     = 4.0
+)
+
+// secretNameKeywords mark a name as secret if they end a "_" separated part of the name
+// (e.g. GITHUB_TOKEN, DB_PASSWORD, ACCESSTOKEN).
+var secretNameKeywords = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD"}
+
+// secretKeyQualifiers mark a name ending with the word KEY as secret if they come right before
+// it (e.g. OPENAI_API_KEY, AWS_SECRET_ACCESS_KEY). KEY alone is too common: MONKEY, GPG_KEY
+// and XDG_SESSION_KEY hold no secret, and in PRIMARY_KEY_ID it is not the last word.
+var secretKeyQualifiers = []string{"API", "ACCESS", "SECRET", "PRIVATE", "SIGNING", "ENCRYPTION", "MASTER", "AUTH"}
+
+// secretValuePrefixes are well known prefixes of API tokens.
+var secretValuePrefixes = []string{
+	"ghp_", "gho_", "ghu_", "ghs_", "ghr_", "github_pat_", // GitHub
+	"glpat-",                                    // GitLab
+	"xoxb-", "xoxp-", "xoxa-", "xoxr-", "xapp-", // Slack
+	"sk_live_", "sk_test_", "rk_live_", // Stripe
+	"AKIA", "ASIA", // AWS access keys
+	"AIza",                            // Google API keys
+	"npm_", "pypi-", "hf_", "dop_v1_", // Package registries, Hugging Face, DigitalOcean
+	"shpat_", "shpss_", // Shopify
+}
+
+// urlCredentials finds the password of user:pass@ inside URLs.
+var urlCredentials = regexp.MustCompile(`([A-Za-z][A-Za-z0-9+.-]*://[^/@:\s]*:)([^/@\s]+)@`)
+
+// openAIKey matches OpenAI keys, "sk-" followed by a token (not words like sk-learn).
+var openAIKey = regexp.MustCompile(`^sk-([a-z]+-)?[A-Za-z0-9_-]*[0-9][A-Za-z0-9_-]*$`)
+
+// tokenCharacters are the characters found in tokens and base64 encoded keys.
+var tokenCharacters = regexp.MustCompile(`^[A-Za-z0-9+/=_.-]+$`)
+
+// IsValidMaskStyle returns true for the supported mask_style settings.
+func IsValidMaskStyle(style string) bool {
+	return style == MaskStyleFull || style == MaskStylePartial
+}
+
+// SetMasking selects how values are masked and enables heuristic secret detection
+// in addition to the password setting.
+func (out *Output) SetMasking(detectSecrets bool, style string) {
+	out.detectSecrets = detectSecrets
+	out.maskStyle = style
+}
+
+// MaskValue returns the value to display for a variable, and true if it was masked.
+// Password variables are always masked, other variables only if secret detection is
+// enabled. Credentials inside URLs are masked without hiding the rest of the URL.
+func (out *Output) MaskValue(name, value string) (string, bool) {
+	if out.reveal {
+		return value, false
+	}
+	if out.passwords.MatchAny(name) {
+		return out.maskSecret(value), true
+	}
+	if !out.detectSecrets || out.paths.MatchAny(name) {
+		return value, false
+	}
+	if IsSecretName(name) || IsSecretValue(value) {
+		return out.maskSecret(value), true
+	}
+	if urlCredentials.MatchString(value) {
+		return urlCredentials.ReplaceAllString(value, "${1}"+partialMaskPrefix+"@"), true
+	}
+	return value, false
+}
+
+func (out *Output) maskSecret(value string) string {
+	if runes := []rune(value); out.maskStyle == MaskStylePartial && len(runes) >= partialMinSize {
+		return partialMaskPrefix + string(runes[len(runes)-partialVisibleSize:])
+	}
+	return MaskedValue
+}
+
+// IsSecretName returns true if the name suggests the variable holds a secret.
+func IsSecretName(name string) bool {
+	parts := strings.Split(strings.ToUpper(name), "_")
+	if last := parts[len(parts)-1]; strings.HasSuffix(last, "APIKEY") {
+		return true
+	} else if last == "KEY" && len(parts) > 1 && contains(secretKeyQualifiers, parts[len(parts)-2]) {
+		return true
+	}
+	for _, part := range parts {
+		for _, keyword := range secretNameKeywords {
+			if strings.HasSuffix(part, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsSecretValue returns true if the value starts with a well known token prefix
+// or looks like a random key. Values with a "/" are never considered random, as
+// long paths and URLs have a high entropy too.
+func IsSecretValue(value string) bool {
+	if len(value) < 16 || !tokenCharacters.MatchString(value) {
+		return false
+	}
+	for _, prefix := range secretValuePrefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	if openAIKey.MatchString(value) {
+		return true
+	}
+	return len(value) >= minEntropySize && !strings.Contains(value, "/") && entropy(value) >= minEntropyBits
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	bits := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
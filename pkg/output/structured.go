@@ -0,0 +1,211 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Supported values for the --output flag.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// MaskedValue replaces the value of password variables unless they are revealed.
+const MaskedValue = "****--->hidden<---****"
+
+// Variable is the structured representation of an environment variable.
+type Variable struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Masked  bool   `json:"masked"`
+	Path    bool   `json:"path"`
+	Changed bool   `json:"changed"`
+}
+
+// Group is the structured representation of a group in the environment listing.
+type Group struct {
+	Name      string     `json:"name"`
+	Hidden    bool       `json:"hidden"`
+	Collapsed bool       `json:"collapsed"`
+	Variables []Variable `json:"variables"`
+}
+
+// ProfileState is the structured representation of a profile.
+type ProfileState struct {
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+// IsValidFormat returns true for the formats supported by Encode.
+func IsValidFormat(format string) bool {
+	return format == FormatText || format == FormatJSON || format == FormatYAML
+}
+
+// SetReveal disables masking of password variables in structured output.
+func (out *Output) SetReveal(reveal bool) {
+	out.reveal = reveal
+}
+
+// NewVariable builds the structured representation of a variable, masking secrets unless revealed.
+func (out *Output) NewVariable(name, value string) Variable {
+	v := Variable{
+		Name:    name,
+		Value:   value,
+		Path:    out.IsPathVariable(name),
+		Changed: out.diffNames != nil && out.diffNames[name],
+	}
+	v.Value, v.Masked = out.MaskValue(name, value)
+	return v
+}
+
+// Encode writes v to w as indented JSON or as YAML (using the same field names as JSON).
+func Encode(w io.Writer, format string, v interface{}) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case FormatYAML:
+		// Encoding through JSON keeps the field names, omitempty and map key handling identical
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.UseNumber()
+		node, err := readYAMLNode(decoder)
+		if err != nil {
+			return err
+		}
+		var out strings.Builder
+		if node.isMapping() || node.isSequence() {
+			writeYAMLBlock(&out, node, 0)
+		} else {
+			writeYAMLNode(&out, node, 0)
+		}
+		_, err = io.WriteString(w, strings.TrimPrefix(out.String(), " "))
+		return err
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// yamlNode is a decoded JSON value: a scalar, a mapping (keys in JSON order) or a sequence.
+type yamlNode struct {
+	scalar string
+	keys   []string
+	values []*yamlNode
+	items  []*yamlNode
+	kind   json.Delim
+}
+
+func (node *yamlNode) isMapping() bool {
+	return node.kind == '{' && len(node.keys) > 0
+}
+
+func (node *yamlNode) isSequence() bool {
+	return node.kind == '[' && len(node.items) > 0
+}
+
+// readYAMLNode reads the next JSON value from decoder.
+func readYAMLNode(decoder *json.Decoder) (*yamlNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		node := &yamlNode{kind: t}
+		for decoder.More() {
+			if t == '{' {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, key.(string))
+			}
+			child, err := readYAMLNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if t == '{' {
+				node.values = append(node.values, child)
+			} else {
+				node.items = append(node.items, child)
+			}
+		}
+		// The closing delimiter
+		_, err = decoder.Token()
+		return node, err
+	case string:
+		return &yamlNode{scalar: yamlString(t)}, nil
+	case json.Number:
+		return &yamlNode{scalar: t.String()}, nil
+	case bool:
+		return &yamlNode{scalar: fmt.Sprint(t)}, nil
+	}
+	return &yamlNode{scalar: "null"}, nil
+}
+
+// writeYAMLBlock writes the entries of a non-empty mapping or sequence, each on its own line.
+func writeYAMLBlock(b *strings.Builder, node *yamlNode, indent int) {
+	for i, key := range node.keys {
+		b.WriteString(strings.Repeat(" ", indent) + yamlKey(key) + ":")
+		writeYAMLNode(b, node.values[i], indent+2)
+	}
+	for _, item := range node.items {
+		b.WriteString(strings.Repeat(" ", indent) + "-")
+		if item.isMapping() {
+			// The first key follows the "-" on the same line
+			var nested strings.Builder
+			writeYAMLBlock(&nested, item, indent+2)
+			b.WriteString(" " + strings.TrimPrefix(nested.String(), strings.Repeat(" ", indent+2)))
+		} else {
+			writeYAMLNode(b, item, indent+2)
+		}
+	}
+}
+
+// writeYAMLNode writes the value following "key:" or "-".
+func writeYAMLNode(b *strings.Builder, node *yamlNode, indent int) {
+	switch {
+	case node.isMapping() || node.isSequence():
+		b.WriteString("\n")
+		writeYAMLBlock(b, node, indent)
+	case node.kind == '{':
+		b.WriteString(" {}\n")
+	case node.kind == '[':
+		b.WriteString(" []\n")
+	default:
+		b.WriteString(" " + node.scalar + "\n")
+	}
+}
+
+var plainYAMLKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// yamlKey returns key unquoted if it can not be read as anything but a string.
+func yamlKey(key string) string {
+	switch strings.ToLower(key) {
+	case "true", "false", "yes", "no", "on", "off", "null", "y", "n":
+		return yamlString(key)
+	}
+	if plainYAMLKey.MatchString(key) {
+		return key
+	}
+	return yamlString(key)
+}
+
+// yamlString double quotes a string, JSON escapes are valid in YAML double quoted scalars.
+func yamlString(s string) string {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
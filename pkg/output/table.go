@@ -0,0 +1,112 @@
+package output
+
+import (
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sverrirab/envirou/pkg/data"
+	"github.com/sverrirab/envirou/pkg/shell"
+)
+
+const (
+	tableGap          = "  "
+	tableEllipsis     = "…"
+	minTableValueSize = 16
+)
+
+// TerminalWidth returns the width of the terminal f is connected to, or 0 if it is not a terminal.
+func TerminalWidth(f *os.File) int {
+	return terminalWidth(f)
+}
+
+// SetTable enables the table view when width is positive: names are aligned in a column,
+// path-like values are split one entry per line and long values are truncated to fit
+// unless wide is set.
+func (out *Output) SetTable(width int, wide bool) {
+	out.tableWidth = width
+	out.tableWide = wide
+}
+
+// PrintEnvs prints the variables of a group, in the table view if enabled.
+func (out *Output) PrintEnvs(sh *shell.Shell, envs data.Envs, profile *data.Profile) {
+	Printf("%s", out.SprintEnvs(sh, envs, profile))
+}
+
+// SprintEnvs formats the variables of a group, in the table view if enabled.
+func (out *Output) SprintEnvs(sh *shell.Shell, envs data.Envs, profile *data.Profile) string {
+	var b strings.Builder
+	if out.tableWidth <= 0 || out.displayRaw {
+		for _, env := range envs {
+			value, _ := profile.Get(env)
+			b.WriteString(out.SprintEnv(sh, env, value))
+		}
+		return b.String()
+	}
+
+	// Very long names are allowed to overflow so they don't push every value off screen
+	column := 0
+	for _, env := range envs {
+		if n := utf8.RuneCountInString(env); n > column && n <= out.tableWidth/3 {
+			column = n
+		}
+	}
+	indent := strings.Repeat(" ", column+len(tableGap))
+
+	for _, env := range envs {
+		value, _ := profile.Get(env)
+		b.WriteString(out.sprintName(env))
+		nameSize := utf8.RuneCountInString(env)
+		if nameSize < column {
+			b.WriteString(strings.Repeat(" ", column-nameSize))
+			nameSize = column
+		}
+		b.WriteString(tableGap)
+		valueSize := out.tableWidth - nameSize - len(tableGap)
+		if valueSize < minTableValueSize {
+			valueSize = minTableValueSize
+		}
+		if masked, ok := out.MaskValue(env, value); ok {
+			if masked != MaskedValue {
+				// Partially masked values and URLs may still be long
+				masked = out.truncate(singleLine(masked), valueSize)
+			}
+			b.WriteString(masked + "\n")
+		} else if out.paths.MatchAny(env) && value != "" {
+			for i, section := range strings.Split(value, pathListSeparator) {
+				if i > 0 {
+					b.WriteString(indent)
+				}
+				section = out.truncate(out.ReplaceHomeTilde(section), valueSize)
+				if i%2 == 1 {
+					section = out.PathSprintf("%s", section)
+				}
+				b.WriteString(section + "\n")
+			}
+		} else {
+			b.WriteString(out.truncate(singleLine(value), valueSize) + "\n")
+		}
+	}
+	return b.String()
+}
+
+func (out *Output) sprintName(name string) string {
+	if out.diffNames != nil && out.diffNames[name] {
+		return out.DiffSprintf("%s", name)
+	}
+	return out.EnvNameSprintf("%s", name)
+}
+
+// truncate shortens value to size characters, ending with an ellipsis, unless the table is wide.
+func (out *Output) truncate(value string, size int) string {
+	if out.tableWide || utf8.RuneCountInString(value) <= size {
+		return value
+	}
+	runes := []rune(value)
+	return string(runes[:size-1]) + tableEllipsis
+}
+
+// singleLine makes line breaks in a value visible so they don't break the table.
+func singleLine(value string) string {
+	return strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(value)
+}
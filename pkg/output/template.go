@@ -0,0 +1,110 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// TemplateVariable is the data available for each variable in --format templates.
+type TemplateVariable struct {
+	Name     string
+	Value    string   // Masked unless --reveal is given
+	Masked   bool     // True if Value has been masked
+	Group    string   // First group (in display order) the variable belongs to, empty if none
+	Groups   []string // All groups the variable belongs to
+	Path     bool     // True if the variable is path-like
+	Changed  bool     // True if the variable changed since the snapshot
+	Status   string   // "added", "changed" or "removed" in diff, empty otherwise
+	Profiles []string // Profiles that set or unset the variable
+}
+
+// TemplateData is the root object passed to --format templates.
+type TemplateData struct {
+	Vars     []TemplateVariable
+	Profiles []ProfileState
+}
+
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// NewTemplate parses a --format template. Like docker, the escapes \t and \n outside of
+// actions are replaced with tab and newline so they can be given without shell quoting tricks.
+// Inside actions they are left alone, so "\n" in a quoted string works as in Go.
+func NewTemplate(text string) (*template.Template, error) {
+	return template.New("format").Funcs(templateFuncs).Option("missingkey=error").Parse(unescapeText(text))
+}
+
+var textEscapes = strings.NewReplacer(`\t`, "\t", `\n`, "\n")
+
+// unescapeText replaces the escapes in the text of a template, skipping the {{ }} actions.
+func unescapeText(text string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			b.WriteString(textEscapes.Replace(text))
+			return b.String()
+		}
+		b.WriteString(textEscapes.Replace(text[:start]))
+		end := actionEnd(text, start+2)
+		b.WriteString(text[start:end])
+		text = text[end:]
+	}
+}
+
+// actionEnd returns the index after the "}}" closing the action starting at i, skipping
+// quoted strings, or len(text) if it is not closed.
+func actionEnd(text string, i int) int {
+	var quote byte
+	for ; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`' || c == '\'':
+			quote = c
+		case strings.HasPrefix(text[i:], "}}"):
+			return i + 2
+		}
+	}
+	return len(text)
+}
+
+// ExecuteTemplate writes the result of tmpl to w, adding a final newline if missing.
+func ExecuteTemplate(w io.Writer, tmpl *template.Template, data TemplateData) error {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return err
+	}
+	if b.Len() > 0 && !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+		b.WriteString("\n")
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// NewTemplateVariable builds the template representation of a variable, masking passwords unless revealed.
+func (out *Output) NewTemplateVariable(name, value string) TemplateVariable {
+	v := out.NewVariable(name, value)
+	return TemplateVariable{
+		Name:    v.Name,
+		Value:   v.Value,
+		Masked:  v.Masked,
+		Path:    v.Path,
+		Changed: v.Changed,
+	}
+}
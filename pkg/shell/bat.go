@@ -0,0 +1,46 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bat is the backend for cmd.exe. The ev.cmd wrapper saves the output to a temporary
+// batch file and runs it with call, so the usual batch file parsing rules apply.
+type bat struct{}
+
+// batReplacer escapes the characters special to cmd.exe with ^ and doubles % (which
+// would otherwise expand variables in a batch file).
+var batReplacer = strings.NewReplacer(
+	"^", "^^", "&", "^&", "|", "^|", "<", "^<", ">", "^>", "(", "^(", ")", "^)", `"`, `^"`, "%", "%%",
+)
+
+func (bat) Escape(value string) string {
+	return batReplacer.Replace(value)
+}
+
+// ExportVar returns a set command. cmd.exe can not set values containing line breaks
+// so a warning is printed instead. Setting an empty value removes the variable.
+func (sh bat) ExportVar(name, value string) string {
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Sprintf("@echo envirou: can not set %s in cmd.exe, the value contains a line break 1>&2", sh.Escape(name))
+	}
+	return fmt.Sprintf("@set %s=%s", sh.Escape(name), sh.Escape(value))
+}
+
+// ValidName accepts any name that can be escaped, cmd.exe splits set at the first =.
+func (bat) ValidName(name string) bool {
+	return quotableName(name)
+}
+
+func (sh bat) UnsetVar(name string) string {
+	return fmt.Sprintf("@set %s=", sh.Escape(name))
+}
+
+func (bat) RunCommands(commands []string) string {
+	// One command per line of the batch file
+	if len(commands) == 0 {
+		return ""
+	}
+	return strings.Join(commands, "\r\n") + "\r\n"
+}
@@ -0,0 +1,39 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Detect returns the name of the shell envirou is running in. The parent process is
+// used if it is a known shell (ev wrappers run envirou from the shell itself), then
+// $SHELL and finally the platform default.
+func Detect() (name string, source string) {
+	return detect(parentProcessName(), os.Getenv("SHELL"), runtime.GOOS)
+}
+
+func detect(parent, shellEnv, goos string) (string, string) {
+	if name, found := knownShell(parent); found {
+		return name, "parent process"
+	}
+	if name, found := knownShell(shellEnv); found {
+		return name, "$SHELL"
+	}
+	if goos == "windows" {
+		return "bat", "default"
+	}
+	return "bash", "default"
+}
+
+// knownShell normalizes a process name or path (e.g. -bash, /usr/bin/fish or pwsh.exe)
+// and returns it if it is a shell Lookup knows.
+func knownShell(process string) (string, bool) {
+	name := strings.ToLower(filepath.Base(strings.ReplaceAll(process, `\`, "/")))
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "-"), ".exe")
+	if _, found := backends[name]; !found {
+		return "", false
+	}
+	return name, true
+}
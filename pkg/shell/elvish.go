@@ -0,0 +1,48 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// elvish is the backend for the elvish shell.
+type elvish struct{}
+
+// elvishBare returns true if value can be given as an elvish bareword.
+func elvishBare(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("/._-:+,%", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func (elvish) Escape(value string) string {
+	if elvishBare(value) {
+		return value
+	}
+	// Single quoted strings have no escapes, a quote is written twice
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(value, "'", "''"))
+}
+
+func (sh elvish) ExportVar(name, value string) string {
+	return fmt.Sprintf("set-env %s %s", sh.Escape(name), sh.Escape(value))
+}
+
+// ValidName accepts any name that can be quoted, set-env takes it as a string.
+func (elvish) ValidName(name string) bool {
+	return quotableName(name)
+}
+
+func (sh elvish) UnsetVar(name string) string {
+	return fmt.Sprintf("unset-env %s", sh.Escape(name))
+}
+
+func (elvish) RunCommands(commands []string) string {
+	return joinCommands(commands, ";")
+}
@@ -0,0 +1,52 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fish is the backend for the fish shell.
+type fish struct{}
+
+// fishSpecial are characters needsEscape allows that fish treats specially at the start of a word.
+const fishSpecial = "#%"
+
+func (fish) Escape(value string) string {
+	if needsEscape(value) || value == "" || strings.ContainsAny(value, fishSpecial) {
+		// Inside fish single quotes only \\ and \' are escapes
+		return fmt.Sprintf("'%s'", strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value))
+	}
+	return value
+}
+
+func (sh fish) ExportVar(name, value string) string {
+	return fmt.Sprintf("set -gx %s %s", name, sh.escapeList(name, value))
+}
+
+func (fish) ValidName(name string) bool {
+	return portableName(name)
+}
+
+func (fish) UnsetVar(name string) string {
+	return fmt.Sprintf("set -e %s", name)
+}
+
+func (fish) RunCommands(commands []string) string {
+	return joinCommands(commands, ";")
+}
+
+// escapeList escapes value, splitting it into a list for fish path variables.
+// This follows fish's own rule rather than the path setting: every variable whose name
+// ends in PATH (case sensitive) is a colon separated list that fish joins with colons
+// again when exported, so each entry is given as a separate element. Other variables
+// are set as a single string, which fish exports unchanged.
+func (sh fish) escapeList(name, value string) string {
+	if !strings.HasSuffix(name, "PATH") || !strings.Contains(value, ":") {
+		return sh.Escape(value)
+	}
+	entries := strings.Split(value, ":")
+	for i, entry := range entries {
+		entries[i] = sh.Escape(entry)
+	}
+	return strings.Join(entries, " ")
+}
@@ -0,0 +1,80 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// nushell is the backend for nu. Nu can not evaluate generated code, so the output is a
+// NUON record of the changes (null to remove a variable) applied by the ev command.
+type nushell struct{}
+
+func (nushell) Escape(value string) string {
+	if !strings.ContainsAny(value, "'") && !hasControl(value) {
+		return fmt.Sprintf("'%s'", value)
+	}
+	// Double quoted strings support backslash escapes
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < ' ' || r == 0x7f {
+				fmt.Fprintf(&b, `\u{%x}`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func (sh nushell) ExportVar(name, value string) string {
+	return fmt.Sprintf("%s: %s", sh.key(name), sh.Escape(value))
+}
+
+// ValidName accepts any name that can be quoted as a record key.
+func (nushell) ValidName(name string) bool {
+	return quotableName(name)
+}
+
+func (sh nushell) UnsetVar(name string) string {
+	return fmt.Sprintf("%s: null", sh.key(name))
+}
+
+func (nushell) RunCommands(commands []string) string {
+	if len(commands) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("{%s}\n", strings.Join(commands, ", "))
+}
+
+// envOnly marks nushell as not running commands, the record only holds variables.
+func (nushell) envOnly() {}
+
+func (sh nushell) key(name string) string {
+	if portableName(name) {
+		return name
+	}
+	return sh.Escape(name)
+}
+
+// hasControl returns true if s contains ASCII control characters.
+func hasControl(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < ' ' || s[i] == 0x7f {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// posix is the backend for bash, zsh and other POSIX shells. With ansiC values containing
+// control characters or invalid UTF-8 are written with $'...' quoting (bash, zsh and ksh)
+// so the output stays on one line.
+type posix struct {
+	ansiC bool
+}
+
+// needsEscape returns true unless value only has characters that are literal in a
+// POSIX shell word after NAME= (no globs, separators, expansions or quotes).
+func needsEscape(value string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'a' <= c && c <= 'z':
+			continue
+		case 'A' <= c && c <= 'Z':
+			continue
+		case '0' <= c && c <= '9':
+			continue
+		case c == '/':
+			continue
+		case c == ':':
+			continue
+		case c == '_':
+			continue
+		case c == '-':
+			continue
+		case c == '+':
+			continue
+		case c == '.':
+			continue
+		case c == ',':
+			continue
+		case c == '!':
+			continue
+		case c == '#':
+			continue
+		case c == '=':
+			continue
+		case c == '@':
+			continue
+		case c == '%':
+			continue
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// needsANSIC returns true if value has control characters or is not valid UTF-8.
+func needsANSIC(value string) bool {
+	return hasControl(value) || !utf8.ValidString(value)
+}
+
+func (sh posix) Escape(value string) string {
+	if sh.ansiC && needsANSIC(value) {
+		return ansiCQuote(value)
+	}
+	if needsEscape(value) {
+		return fmt.Sprintf("'%s'", strings.ReplaceAll(value, "'", "'\\''"))
+	}
+	return value
+}
+
+// ansiCQuote returns value as a $'...' string, escaping quotes, backslashes, control
+// characters and bytes that are not valid UTF-8.
+func ansiCQuote(value string) string {
+	var b strings.Builder
+	b.WriteString("$'")
+	for i := 0; i < len(value); {
+		r, size := utf8.DecodeRuneInString(value[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, `\x%02x`, value[i])
+		case r == '\'' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < ' ' || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteString(value[i : i+size])
+		}
+		i += size
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+func (sh posix) ExportVar(name, value string) string {
+	return fmt.Sprintf("export %s=%s", name, sh.Escape(value))
+}
+
+// ValidName only accepts portable names, the name is part of the command unquoted.
+func (posix) ValidName(name string) bool {
+	return portableName(name)
+}
+
+func (posix) UnsetVar(name string) string {
+	return fmt.Sprintf("unset %s", name)
+}
+
+func (posix) RunCommands(commands []string) string {
+	// Unixes require ; termination (as well as PowerShell)
+	// commands = append(commands, "")  // Bash does not like this
+	return joinCommands(commands, ";")
+}
+
+// joinCommands joins commands with sep and a final newline, empty if there are no commands.
+func joinCommands(commands []string, sep string) string {
+	if len(commands) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s\n", strings.Join(commands, sep))
+}
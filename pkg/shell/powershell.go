@@ -0,0 +1,44 @@
+package shell
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// powerShell is the backend for Windows PowerShell and pwsh.
+type powerShell struct{}
+
+// powerShellQuotes are the characters PowerShell accepts as single quotes, each is
+// escaped by writing it twice.
+var powerShellQuotes = strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019", "\u201a", "\u201a\u201a", "\u201b", "\u201b\u201b")
+
+func (powerShell) Escape(value string) string {
+	return fmt.Sprintf("'%s'", powerShellQuotes.Replace(value))
+}
+
+// powerShellNamePattern matches portable names and Windows names such as ProgramFiles(x86).
+var powerShellNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\([A-Za-z0-9_]*\))?$`)
+
+func (powerShell) ValidName(name string) bool {
+	return powerShellNamePattern.MatchString(name)
+}
+
+// ExportVar uses the ${Env:NAME} form for names with parentheses.
+func (sh powerShell) ExportVar(name, value string) string {
+	if !portableName(name) {
+		return fmt.Sprintf("${Env:%s} = %s", name, sh.Escape(value))
+	}
+	return fmt.Sprintf("$Env:%s = %s", name, sh.Escape(value))
+}
+
+func (sh powerShell) UnsetVar(name string) string {
+	if !portableName(name) {
+		return fmt.Sprintf("Remove-Item %s", sh.Escape("Env:"+name))
+	}
+	return fmt.Sprintf("Remove-Item Env:%s", name)
+}
+
+func (powerShell) RunCommands(commands []string) string {
+	return joinCommands(commands, ";")
+}
@@ -0,0 +1,15 @@
+package shell
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func parentProcessName() string {
+	proc, err := unix.SysctlKinfoProc("kern.proc.pid", os.Getppid())
+	if err != nil {
+		return ""
+	}
+	return unix.ByteSliceToString(proc.Proc.P_comm[:])
+}
@@ -0,0 +1,15 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func parentProcessName() string {
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", os.Getppid()))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
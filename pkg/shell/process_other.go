@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || windows)
+
+package shell
+
+// parentProcessName is not implemented on this platform, detection falls back to $SHELL.
+func parentProcessName() string {
+	return ""
+}
@@ -0,0 +1,79 @@
+package shell
+
+import (
+	"math/rand"
+	"os/exec"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// shellValue is an arbitrary environment value: any bytes except NUL, biased towards
+// characters that are special to some shell.
+type shellValue string
+
+const specialBytes = "'\"\\$`!#*?;&|<>(){}[]~%^@= \t\n\r\x1b\x7f"
+
+func (shellValue) Generate(rand *rand.Rand, size int) reflect.Value {
+	b := make([]byte, rand.Intn(size+1))
+	plain := rand.Intn(2) == 0 // Only letters and one special byte, so it is the only reason to quote
+	for i := range b {
+		switch n := rand.Intn(4); {
+		case plain || n > 1:
+			b[i] = byte('a' + rand.Intn(26))
+		case n == 0:
+			b[i] = specialBytes[rand.Intn(len(specialBytes))]
+		default:
+			b[i] = byte(1 + rand.Intn(255))
+		}
+	}
+	if plain && len(b) > 0 {
+		b[rand.Intn(len(b))] = specialBytes[rand.Intn(len(specialBytes))]
+	}
+	// Sometimes add valid multi-byte UTF-8
+	if rand.Intn(4) == 0 {
+		b = append(b, "blåbær ☃ ’"...)
+	}
+	return reflect.ValueOf(shellValue(b))
+}
+
+// TestRoundTrip sets random values through the commands of each backend in the real shell
+// (skipped if not installed) and checks the value seen by a child process.
+func TestRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("printenv"); err != nil {
+		t.Skip("printenv not found")
+	}
+	for _, test := range []struct {
+		backend string
+		command []string
+	}{
+		{"sh", []string{"sh", "-c"}},
+		{"dash", []string{"dash", "-c"}},
+		{"bash", []string{"bash", "-c"}},
+		{"zsh", []string{"zsh", "-f", "-c"}},
+		{"ksh", []string{"ksh", "-c"}},
+		{"fish", []string{"fish", "--no-config", "-c"}},
+		{"elvish", []string{"elvish", "-norc", "-c"}},
+		{"tcsh", []string{"tcsh", "-f", "-c"}},
+	} {
+		t.Run(test.backend, func(t *testing.T) {
+			if _, err := exec.LookPath(test.command[0]); err != nil {
+				t.Skipf("%s not found", test.command[0])
+			}
+			sh, _ := Lookup(test.backend)
+			roundTrip := func(value shellValue) bool {
+				script := sh.RunCommands([]string{sh.ExportVar("ENVIROU_ROUND_TRIP", string(value))}) + "printenv ENVIROU_ROUND_TRIP\n"
+				args := append(test.command[1:], script)
+				out, err := exec.Command(test.command[0], args...).Output()
+				if err != nil || string(out) != string(value)+"\n" {
+					t.Logf("%s: %q\n  script: %q\n  output: %q (%v)", test.backend, value, script, out, err)
+					return false
+				}
+				return true
+			}
+			if err := quick.Check(roundTrip, &quick.Config{MaxCount: 300}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
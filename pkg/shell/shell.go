@@ -1,116 +1,117 @@
 package shell
 
 import (
-	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/sverrirab/envirou/pkg/data"
 )
 
+// Backend produces the commands changing the environment in one kind of shell.
+type Backend interface {
+	// ExportVar returns the command setting name to value.
+	ExportVar(name, value string) string
+	// UnsetVar returns the command removing name.
+	UnsetVar(name string) string
+	// Escape quotes value so the shell reads it literally.
+	Escape(value string) string
+	// ValidName returns true if name can be set or removed safely in the shell.
+	ValidName(name string) bool
+	// RunCommands joins commands into the output evaluated by the ev wrapper.
+	RunCommands(commands []string) string
+}
+
+// envOnly is implemented by backends whose output can only change the environment, so other
+// commands (such as starting an editor) can not be run through the ev wrapper.
+type envOnly interface {
+	envOnly()
+}
+
 type Shell struct {
-	bat        bool
-	powerShell bool
+	Backend
 }
 
-func NewShell(powerShell bool, bat bool) *Shell {
-	return &Shell{
-		powerShell: powerShell,
-		bat:        bat,
-	}
+// backends maps shell names (and aliases) to their backend.
+var backends = map[string]Backend{
+	"bash":       posix{ansiC: true},
+	"zsh":        posix{ansiC: true},
+	"ksh":        posix{ansiC: true},
+	"sh":         posix{},
+	"dash":       posix{},
+	"fish":       fish{},
+	"powershell": powerShell{},
+	"pwsh":       powerShell{},
+	"bat":        bat{},
+	"cmd":        bat{},
+	"nushell":    nushell{},
+	"nu":         nushell{},
+	"elvish":     elvish{},
+	"tcsh":       tcsh{},
+	"csh":        tcsh{},
 }
 
-func needsEscape(value string) bool {
-	for i := 0; i < len(value); i++ {
-		c := value[i]
-		switch {
-		case 'a' <= c && c <= 'z':
-			continue
-		case 'A' <= c && c <= 'Z':
-			continue
-		case '0' <= c && c <= '9':
-			continue
-		case c == '/':
-			continue
-		case c == ':':
-			continue
-		case c == ';':
-			continue
-		case c == '_':
-			continue
-		case c == '-':
-			continue
-		case c == '+':
-			continue
-		case c == '.':
-			continue
-		case c == '?':
-			continue
-		case c == ',':
-			continue
-		case c == '!':
-			continue
-		case c == '#':
-			continue
-		case c == '=':
-			continue
-		case c == '*':
-			continue
-		default:
-			return true
-		}
+// Lookup returns the Shell for a shell name such as bash, fish or nu.
+func Lookup(name string) (*Shell, bool) {
+	backend, found := backends[name]
+	if !found {
+		return nil, false
 	}
-	return false
+	return &Shell{backend}, true
 }
 
-func (shell *Shell) Escape(value string) string {
-	if shell.powerShell {
-		return fmt.Sprintf("'%s'", strings.ReplaceAll(value, "'", "''"))
-	} else if needsEscape(value) {
-		return fmt.Sprintf("'%s'", strings.ReplaceAll(value, "'", "'\\''"))
-	} else {
-		return value
+// Names returns all shell names accepted by Lookup, sorted.
+func Names() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
 }
 
-func (shell *Shell) ExportVar(name, value string) string {
-	if shell.powerShell {
-		return fmt.Sprintf("$Env:%s = %s", name, shell.Escape(value))
-	} else if shell.bat {
-		return fmt.Sprintf("set %s=%s", name, shell.Escape(value))
-	} else {
-		return fmt.Sprintf("export %s=%s", name, shell.Escape(value))
-	}
+// portableNamePattern matches the variable names every shell accepts unquoted.
+var portableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// portableName returns true for names of letters, digits and underscores not starting with a digit.
+func portableName(name string) bool {
+	return portableNamePattern.MatchString(name)
 }
 
-func (shell *Shell) UnsetVar(name string) string {
-	if shell.powerShell {
-		return fmt.Sprintf("Remove-Item Env:%s", name)
-	} else if shell.bat {
-		return fmt.Sprintf("set %s=", name)
-	} else {
-		return fmt.Sprintf("unset %s", name)
-	}
+// quotableName returns true for names that are safe once quoted: not empty, no = and no control characters.
+func quotableName(name string) bool {
+	return name != "" && !strings.Contains(name, "=") && !hasControl(name)
+}
+
+// CanRunCommands returns false if the ev wrapper of the shell only applies environment changes.
+func (shell *Shell) CanRunCommands() bool {
+	_, only := shell.Backend.(envOnly)
+	return !only
 }
 
-func (shell *Shell) RunCommands(commands []string) string {
-	if len(commands) == 0 {
-		return ""
-	} else {
-		if shell.bat {
-			// Windows bat file use & as seperator
-			return fmt.Sprintf("%s\n", strings.Join(commands, " & "))
-		} else {
-			// Unixes require ; termination (as well as PowerShell)
-			// commands = append(commands, "")  // Bash does not like this
-			return fmt.Sprintf("%s\n", strings.Join(commands, ";"))
+// InvalidNames returns the names the shell can not set or remove safely, in order.
+func (shell *Shell) InvalidNames(names []string) (invalid []string) {
+	for _, name := range names {
+		if !shell.ValidName(name) {
+			invalid = append(invalid, name)
 		}
 	}
+	return
 }
 
 func (shell *Shell) GetCommands(old, new *data.Profile) (commands []string) {
+	return shell.GetDisplayCommands(old, new, nil)
+}
+
+// GetDisplayCommands returns the commands of GetCommands with exported values passed through
+// mask (if not nil), so they can be shown without revealing secrets.
+func (shell *Shell) GetDisplayCommands(old, new *data.Profile, mask func(name, value string) (string, bool)) (commands []string) {
 	added, removed := old.Diff(new)
 	for _, add := range added {
 		value, _ := new.Get(add)
+		if mask != nil {
+			value, _ = mask(add, value)
+		}
 		commands = append(commands, shell.ExportVar(add, value))
 	}
 	for _, remove := range removed {
@@ -1,27 +1,34 @@
 package shell
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/sverrirab/envirou/pkg/data"
 )
 
+// lookupShell returns the Shell for a name known to exist.
+func lookupShell(name string) *Shell {
+	sh, _ := Lookup(name)
+	return sh
+}
+
 func validateEscaped(t *testing.T, s string) {
-	sh := NewShell(false, false)
+	sh := lookupShell("bash")
 	if s == sh.Escape(s) {
 		t.Errorf("Should be escaped %s == %s", s, sh.Escape(s))
 	}
 }
 
 func validateExact(t *testing.T, original, expected string) {
-	sh := NewShell(false, false)
+	sh := lookupShell("bash")
 	if expected != sh.Escape(original) {
 		t.Errorf("Incorrect escape of %s:\n  EXPECT: %s.\n  ACTUAL: %s.\n", original, expected, sh.Escape(original))
 	}
 }
 
 func validateUnEscaped(t *testing.T, s string) {
-	sh := NewShell(false, false)
+	sh := lookupShell("bash")
 	if s != sh.Escape(s) {
 		t.Errorf("Should not be escaped  %s == %s", s, sh.Escape(s))
 	}
@@ -32,14 +39,15 @@ func TestEscape(t *testing.T) {
 	validateEscaped(t, "$hi")
 	validateEscaped(t, "what~up")
 	validateEscaped(t, "what`up")
+	validateEscaped(t, "How*Tri+cks?")
+	validateEscaped(t, "a;b")
 
 	validateExact(t, "hi", "hi")
 	validateExact(t, "'why don't you do that to me?'", "''\\''why don'\\''t you do that to me?'\\'''")
 
 	validateUnEscaped(t, "hello")
 	validateUnEscaped(t, "He.l0981o-World!")
-	validateUnEscaped(t, "How*Tri+cks?")
-	validateUnEscaped(t, "/:;_,-!#=*")
+	validateUnEscaped(t, "/:_,-!#=@%")
 }
 
 func TestCommandsBash(t *testing.T) {
@@ -50,7 +58,7 @@ func TestCommandsBash(t *testing.T) {
 	after := data.NewProfile(false)
 	after.MergeStrings(e2)
 
-	sh := NewShell(false, false)
+	sh := lookupShell("bash")
 	commands := sh.GetCommands(before, after)
 	expected := "foobar"
 	if len(commands) != 2 || commands[0] != "export SMURF='yes yes'" || commands[1] != "unset FOO" {
@@ -66,17 +74,106 @@ func TestCommandsBat(t *testing.T) {
 	after := data.NewProfile(false)
 	after.MergeStrings(e2)
 
-	sh := NewShell(false, true)
-	// shellBash := NewShell(false, false)
+	sh := lookupShell("bat")
 	commands := sh.GetCommands(before, after)
 	expected := "foobar"
-	if len(commands) != 2 || commands[0] != "set SMURF='yes yes'" || commands[1] != "set FOO=" {
+	if len(commands) != 2 || commands[0] != "@set SMURF=yes yes" || commands[1] != "@set FOO=" {
 		t.Errorf("Invalid commands:\n  EXPECT: %s.\n  ACTUAL: %s\n", expected, commands)
 	}
 }
 
+func TestEscapeANSIC(t *testing.T) {
+	bash, _ := Lookup("bash")
+	sh, _ := Lookup("sh")
+	for original, expected := range map[string]string{
+		"line1\nline2":  `$'line1\nline2'`,
+		"tab\there":     `$'tab\there'`,
+		"it's\n":        `$'it\'s\n'`,
+		"back\\slash\r": `$'back\\slash\r'`,
+		"bell\a":        `$'bell\x07'`,
+		"blå\xff\n":     `$'blå\xff\n'`,
+		"no controls":   `'no controls'`,
+	} {
+		if actual := bash.Escape(original); actual != expected {
+			t.Errorf("Incorrect bash escape of %q:\n  EXPECT: %s.\n  ACTUAL: %s.\n", original, expected, actual)
+		}
+	}
+	if actual := sh.Escape("line1\nline2"); actual != "'line1\nline2'" {
+		t.Errorf("Expected sh to use single quotes, got: %s", actual)
+	}
+}
+
+func TestEscapeBat(t *testing.T) {
+	sh, _ := Lookup("bat")
+	for original, expected := range map[string]string{
+		"plain":            "@set X=plain",
+		"a&b|c":            "@set X=a^&b^|c",
+		`say "hi" <there>`: `@set X=say ^"hi^" ^<there^>`,
+		"100% (^)":         "@set X=100%% ^(^^^)",
+		"%PATH%":           "@set X=%%PATH%%",
+	} {
+		if actual := sh.ExportVar("X", original); actual != expected {
+			t.Errorf("Incorrect bat command for %q:\n  EXPECT: %s.\n  ACTUAL: %s.\n", original, expected, actual)
+		}
+	}
+	if actual := sh.ExportVar("X", "a\nb"); !strings.HasPrefix(actual, "@echo envirou: can not set X") {
+		t.Errorf("Expected warning for line break, got: %s", actual)
+	}
+}
+
+func TestEscapePowerShell(t *testing.T) {
+	sh, _ := Lookup("powershell")
+	if actual := sh.Escape("it's ‘quoted’"); actual != "'it''s ‘‘quoted’’'" {
+		t.Errorf("Incorrect PowerShell escape: %s", actual)
+	}
+}
+
+func TestEscapeFish(t *testing.T) {
+	sh, _ := Lookup("fish")
+	for original, expected := range map[string]string{
+		"hello":         "hello",
+		"":              "''",
+		"hello world":   "'hello world'",
+		"don't":         `'don\'t'`,
+		`back\slash`:    `'back\\slash'`,
+		"$HOME":         "'$HOME'",
+		"*.go":          "'*.go'",
+		"what?":         "'what?'",
+		"a;b":           "'a;b'",
+		"#comment":      "'#comment'",
+		"/usr/local/go": "/usr/local/go",
+	} {
+		if actual := sh.Escape(original); actual != expected {
+			t.Errorf("Incorrect fish escape of %s:\n  EXPECT: %s.\n  ACTUAL: %s.\n", original, expected, actual)
+		}
+	}
+}
+
+func TestCommandsFish(t *testing.T) {
+	before := data.NewProfile(false)
+	before.MergeStrings([]string{"FOO=2", "SMURF="})
+	after := data.NewProfile(false)
+	after.MergeStrings([]string{"SMURF=yes yes", "PYTHONPATH=/a:/b c", "MANPATH=/usr/man", "URL=http://x:80", "LD_PRELOAD=/a:/b", "my_path=/a:/b", "FOO"})
+
+	sh, _ := Lookup("fish")
+	commands := strings.Join(sh.GetCommands(before, after), ";")
+	for _, expected := range []string{
+		"set -gx SMURF 'yes yes'",
+		"set -gx PYTHONPATH /a '/b c'",
+		"set -gx MANPATH /usr/man",
+		"set -gx URL http://x:80",
+		"set -gx LD_PRELOAD /a:/b",
+		"set -gx my_path /a:/b",
+		"set -e FOO",
+	} {
+		if !strings.Contains(commands, expected) {
+			t.Errorf("Expected %s in commands: %s", expected, commands)
+		}
+	}
+}
+
 func TestRunCommandsBash(t *testing.T) {
-	sh := NewShell(false, false)
+	sh := lookupShell("bash")
 	cmd1 := sh.RunCommands([]string{})
 	if cmd1 != "" {
 		t.Errorf("Did not expect no command to be: %s.", cmd1)
@@ -88,13 +185,82 @@ func TestRunCommandsBash(t *testing.T) {
 }
 
 func TestRunCommandsBat(t *testing.T) {
-	sh := NewShell(false, true)
+	sh := lookupShell("bat")
 	cmd1 := sh.RunCommands([]string{})
 	if cmd1 != "" {
 		t.Errorf("Did not expect no command to be: %s.", cmd1)
 	}
 	cmd2 := sh.RunCommands([]string{"echo hi", "ls -al"})
-	if cmd2 != "echo hi & ls -al\n" {
+	if cmd2 != "echo hi\r\nls -al\r\n" {
 		t.Errorf("Did not expect commands to be: %s.", cmd2)
 	}
 }
+
+func TestDisplayCommands(t *testing.T) {
+	before := data.NewProfile(false)
+	before.MergeStrings([]string{"FOO=1"})
+	after := data.NewProfile(false)
+	after.MergeStrings([]string{"FOO=1", "TOKEN=secret", "PLAIN=value"})
+	sh := lookupShell("bash")
+	mask := func(name, value string) (string, bool) {
+		if name == "TOKEN" {
+			return "hidden", true
+		}
+		return value, false
+	}
+	commands := strings.Join(sh.GetDisplayCommands(before, after, mask), ";")
+	if !strings.Contains(commands, "export PLAIN=value") || !strings.Contains(commands, "export TOKEN=hidden") || strings.Contains(commands, "secret") {
+		t.Errorf("Invalid display commands: %v", commands)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		parent, shellEnv, goos string
+		expected, source       string
+	}{
+		{"bash", "/bin/zsh", "linux", "bash", "parent process"},
+		{"-zsh", "", "darwin", "zsh", "parent process"},
+		{"fish", "/bin/bash", "linux", "fish", "parent process"},
+		{"pwsh.exe", "", "windows", "pwsh", "parent process"},
+		{"CMD.EXE", "", "windows", "cmd", "parent process"},
+		{"go", "/usr/local/bin/fish", "linux", "fish", "$SHELL"},
+		{"", "/run/current-system/sw/bin/nu", "linux", "nu", "$SHELL"},
+		{"python3", "", "linux", "bash", "default"},
+		{"explorer.exe", "", "windows", "bat", "default"},
+	}
+	for _, test := range tests {
+		name, source := detect(test.parent, test.shellEnv, test.goos)
+		if name != test.expected || source != test.source {
+			t.Errorf("detect(%q, %q, %s) = %s from %s, expected %s from %s", test.parent, test.shellEnv, test.goos, name, source, test.expected, test.source)
+		}
+	}
+}
+
+func TestInvalidNames(t *testing.T) {
+	names := []string{"PATH", "_private", "lower_case1", "X;rm -rf ~;Y", "1ST", "A B", "A=B", "", "ProgramFiles(x86)", "NEW\nLINE", "$(id)"}
+	for shellName, expected := range map[string][]string{
+		"bash":       {"X;rm -rf ~;Y", "1ST", "A B", "A=B", "", "ProgramFiles(x86)", "NEW\nLINE", "$(id)"},
+		"fish":       {"X;rm -rf ~;Y", "1ST", "A B", "A=B", "", "ProgramFiles(x86)", "NEW\nLINE", "$(id)"},
+		"tcsh":       {"X;rm -rf ~;Y", "1ST", "A B", "A=B", "", "ProgramFiles(x86)", "NEW\nLINE", "$(id)"},
+		"powershell": {"X;rm -rf ~;Y", "1ST", "A B", "A=B", "", "NEW\nLINE", "$(id)"},
+		"bat":        {"A=B", "", "NEW\nLINE"},
+		"nushell":    {"A=B", "", "NEW\nLINE"},
+		"elvish":     {"A=B", "", "NEW\nLINE"},
+	} {
+		sh, _ := Lookup(shellName)
+		if actual := sh.InvalidNames(names); strings.Join(actual, "|") != strings.Join(expected, "|") {
+			t.Errorf("Invalid names for %s:\n  EXPECT: %q\n  ACTUAL: %q", shellName, expected, actual)
+		}
+	}
+}
+
+func TestPowerShellWindowsName(t *testing.T) {
+	sh, _ := Lookup("powershell")
+	if actual := sh.ExportVar("ProgramFiles(x86)", "C:\\x"); actual != "${Env:ProgramFiles(x86)} = 'C:\\x'" {
+		t.Errorf("Incorrect PowerShell command: %s", actual)
+	}
+	if actual := sh.UnsetVar("ProgramFiles(x86)"); actual != "Remove-Item 'Env:ProgramFiles(x86)'" {
+		t.Errorf("Incorrect PowerShell command: %s", actual)
+	}
+}
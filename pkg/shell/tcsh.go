@@ -0,0 +1,36 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tcsh is the backend for tcsh and csh.
+type tcsh struct{}
+
+// tcshReplacer escapes a value inside single quotes. History substitution (!) happens
+// even inside quotes and a newline must be escaped to continue the quoted string.
+var tcshReplacer = strings.NewReplacer(`'`, `'\''`, `!`, `\!`, "\n", "\\\n")
+
+func (tcsh) Escape(value string) string {
+	if needsEscape(value) || value == "" || strings.ContainsAny(value, "!#%") {
+		return fmt.Sprintf("'%s'", tcshReplacer.Replace(value))
+	}
+	return value
+}
+
+func (sh tcsh) ExportVar(name, value string) string {
+	return fmt.Sprintf("setenv %s %s", name, sh.Escape(value))
+}
+
+func (tcsh) ValidName(name string) bool {
+	return portableName(name)
+}
+
+func (tcsh) UnsetVar(name string) string {
+	return fmt.Sprintf("unsetenv %s", name)
+}
+
+func (tcsh) RunCommands(commands []string) string {
+	return joinCommands(commands, ";")
+}
@@ -0,0 +1,534 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sverrirab/envirou/pkg/data"
+	"github.com/sverrirab/envirou/pkg/output"
+)
+
+const (
+	defaultWidth  = 80
+	defaultHeight = 24
+
+	styleReset   = "\x1b[0m"
+	styleBold    = "\x1b[1m"
+	styleReverse = "\x1b[7m"
+
+	enterScreen = "\x1b[?1049h\x1b[?25l" // Alternate screen, hidden cursor
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+)
+
+// Options configure the browser.
+type Options struct {
+	Env             *data.Profile // The current environment, left unchanged
+	Groups          *data.Groups
+	Profiles        data.Profiles
+	Out             *output.Output // Used to mask secrets
+	CaseInsensitive bool
+}
+
+const (
+	viewEnvironment = iota
+	viewProfiles
+)
+
+type row struct {
+	header bool
+	text   string // Group name for headers, variable name otherwise
+}
+
+// toggle is a profile activated or deactivated in the browser.
+type toggle struct {
+	name     string
+	activate bool
+}
+
+// Browser is the state of the environment and profile browser.
+type Browser struct {
+	opts         Options
+	env          *data.Profile // Environment with the profile changes made so far
+	toggles      []toggle      // The profile changes, env is opts.Env with these applied
+	profileNames []string
+
+	view          int
+	filter        string
+	showHidden    bool
+	revealed      map[string]bool
+	rows          []row
+	cursor        int // Index into rows, always a variable row if there is one
+	offset        int // First row shown
+	profileCursor int
+	profileOffset int // First profile shown
+	width         int
+	height        int
+
+	done  bool
+	apply bool
+}
+
+// NewBrowser creates a browser for opts.Env.
+func NewBrowser(opts Options) *Browser {
+	names := make([]string, 0, len(opts.Profiles))
+	for name := range opts.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b := &Browser{
+		opts:         opts,
+		env:          opts.Env.Clone(),
+		profileNames: names,
+		revealed:     make(map[string]bool),
+		width:        defaultWidth,
+		height:       defaultHeight,
+	}
+	b.buildRows()
+	return b
+}
+
+// Run shows the browser on term until the user is done. It returns the resulting
+// environment and true if the changes should be applied (false if cancelled).
+func Run(term Terminal, opts Options) (*data.Profile, bool, error) {
+	b := NewBrowser(opts)
+	if _, err := io.WriteString(term, enterScreen); err != nil {
+		return nil, false, err
+	}
+	defer io.WriteString(term, leaveScreen)
+
+	buf := make([]byte, 256)
+	for !b.done {
+		b.SetSize(term.Size())
+		if _, err := io.WriteString(term, b.frame()); err != nil {
+			return nil, false, err
+		}
+		n, err := term.Read(buf)
+		for _, key := range parseKeys(buf[:n]) {
+			b.HandleKey(key)
+			if b.done {
+				break
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			// Input closed, treat as cancel
+			return b.env, false, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+	}
+	return b.env, b.apply, nil
+}
+
+// Env returns the environment with the changes made so far.
+func (b *Browser) Env() *data.Profile {
+	return b.env
+}
+
+// SetSize sets the size of the screen, zero values keep the defaults.
+func (b *Browser) SetSize(width, height int) {
+	if width > 0 {
+		b.width = width
+	}
+	if height > 0 {
+		b.height = height
+	}
+}
+
+// HandleKey updates the browser state for a key press.
+func (b *Browser) HandleKey(key Key) {
+	switch key.Code {
+	case KeyCtrlC:
+		b.done, b.apply = true, false
+		return
+	case KeyTab:
+		if b.view == viewEnvironment {
+			b.view = viewProfiles
+		} else {
+			b.view = viewEnvironment
+		}
+		return
+	}
+	if b.view == viewProfiles {
+		b.handleProfileKey(key)
+	} else {
+		b.handleEnvironmentKey(key)
+	}
+}
+
+func (b *Browser) handleEnvironmentKey(key Key) {
+	switch key.Code {
+	case KeyRune:
+		b.filter += string(key.Rune)
+		b.buildRows()
+	case KeyBackspace:
+		if b.filter != "" {
+			_, size := utf8.DecodeLastRuneInString(b.filter)
+			b.filter = b.filter[:len(b.filter)-size]
+			b.buildRows()
+		}
+	case KeyCtrlU:
+		b.filter = ""
+		b.buildRows()
+	case KeyEscape:
+		if b.filter != "" {
+			b.filter = ""
+			b.buildRows()
+		} else {
+			b.done, b.apply = true, true
+		}
+	case KeyCtrlT:
+		b.showHidden = !b.showHidden
+		b.buildRows()
+	case KeyEnter, KeyCtrlR:
+		if name, ok := b.selected(); ok {
+			b.revealed[name] = !b.revealed[name]
+		}
+	case KeyUp:
+		b.moveCursor(-1)
+	case KeyDown:
+		b.moveCursor(1)
+	case KeyPageUp:
+		b.moveCursor(-b.bodyHeight())
+	case KeyPageDown:
+		b.moveCursor(b.bodyHeight())
+	case KeyHome:
+		b.moveCursor(-len(b.rows))
+	case KeyEnd:
+		b.moveCursor(len(b.rows))
+	}
+}
+
+func (b *Browser) handleProfileKey(key Key) {
+	switch {
+	case key.Code == KeyUp && b.profileCursor > 0:
+		b.profileCursor--
+	case key.Code == KeyDown && b.profileCursor < len(b.profileNames)-1:
+		b.profileCursor++
+	case key.Code == KeyHome:
+		b.profileCursor = 0
+	case key.Code == KeyEnd && len(b.profileNames) > 0:
+		b.profileCursor = len(b.profileNames) - 1
+	case key.Code == KeyEnter || (key.Code == KeyRune && key.Rune == ' '):
+		if len(b.profileNames) > 0 {
+			b.env, b.toggles = b.toggled(b.profileNames[b.profileCursor])
+			b.buildRows()
+		}
+	case key.Code == KeyEscape || (key.Code == KeyRune && key.Rune == 'q'):
+		b.done, b.apply = true, true
+	}
+}
+
+// toggled returns the environment with the profile activated, or deactivated if it is active,
+// and the resulting toggles. The environment is rebuilt from the original one so deactivating
+// a profile activated in the browser restores the values it replaced.
+func (b *Browser) toggled(name string) (*data.Profile, []toggle) {
+	profile := b.opts.Profiles[name]
+	activate := !b.env.IsMerged(&profile)
+	// Undo an earlier toggle of the profile if that gives the wanted state
+	toggles := make([]toggle, 0, len(b.toggles)+1)
+	for _, t := range b.toggles {
+		if t.name != name {
+			toggles = append(toggles, t)
+		}
+	}
+	if len(toggles) < len(b.toggles) {
+		if env := b.replay(toggles); env.IsMerged(&profile) == activate {
+			return env, toggles
+		}
+	}
+	toggles = append(append(toggles[:0:0], b.toggles...), toggle{name: name, activate: activate})
+	return b.replay(toggles), toggles
+}
+
+// replay returns the original environment with the toggles applied in order. Profiles that
+// were active from the start are unmerged, as the values they replaced are not known.
+func (b *Browser) replay(toggles []toggle) *data.Profile {
+	env := b.opts.Env.Clone()
+	for _, t := range toggles {
+		profile := b.opts.Profiles[t.name]
+		if t.activate {
+			env.Merge(&profile)
+		} else {
+			env.Unmerge(&profile)
+		}
+	}
+	return env
+}
+
+// selected returns the name of the variable under the cursor.
+func (b *Browser) selected() (string, bool) {
+	if b.cursor < len(b.rows) && !b.rows[b.cursor].header {
+		return b.rows[b.cursor].text, true
+	}
+	return "", false
+}
+
+// moveCursor moves by delta variable rows, skipping group headers.
+func (b *Browser) moveCursor(delta int) {
+	target := b.cursor + delta
+	if target < 0 {
+		target = 0
+	}
+	if target >= len(b.rows) {
+		target = len(b.rows) - 1
+	}
+	step := 1
+	if delta < 0 {
+		step = -1
+	}
+	for i := target; i >= 0 && i < len(b.rows); i += step {
+		if !b.rows[i].header {
+			b.cursor = i
+			return
+		}
+	}
+	// Nothing in that direction, search the other way from the target
+	for i := target; i >= 0 && i < len(b.rows); i -= step {
+		if !b.rows[i].header {
+			b.cursor = i
+			return
+		}
+	}
+}
+
+// buildRows lists the groups and variables of the environment matching the filter.
+func (b *Browser) buildRows() {
+	var current string
+	if name, ok := b.selected(); ok {
+		current = name
+	}
+	groups := b.opts.Groups
+	matches, remaining := groups.MatchAll(b.env.SortedNames(false), b.opts.CaseInsensitive)
+	b.rows = b.rows[:0]
+	addGroup := func(name string, envs data.Envs) {
+		header := len(b.rows)
+		for _, env := range envs {
+			if b.matchesFilter(env) {
+				if len(b.rows) == header {
+					b.rows = append(b.rows, row{header: true, text: name})
+				}
+				b.rows = append(b.rows, row{text: env})
+			}
+		}
+	}
+	for _, name := range groups.GetDisplayNames() {
+		if strings.HasPrefix(name, ".") && !b.showHidden {
+			continue
+		}
+		addGroup(name, groups.SortEnvs(name, matches[name], b.env, b.opts.CaseInsensitive))
+	}
+	addGroup("(no group)", remaining)
+
+	// Keep the cursor on the same variable if it is still listed
+	b.cursor = 0
+	for i, r := range b.rows {
+		if !r.header && r.text == current {
+			b.cursor = i
+			break
+		}
+	}
+	b.moveCursor(0)
+}
+
+func (b *Browser) matchesFilter(name string) bool {
+	if b.filter == "" {
+		return true
+	}
+	filter := strings.ToLower(b.filter)
+	return strings.Contains(strings.ToLower(name), filter) || strings.Contains(strings.ToLower(b.displayValue(name)), filter)
+}
+
+// displayValue returns the value of a variable, masked unless revealed.
+func (b *Browser) displayValue(name string) string {
+	value, _ := b.env.Get(name)
+	if !b.revealed[name] {
+		value, _ = b.opts.Out.MaskValue(name, value)
+	}
+	return strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(value)
+}
+
+func (b *Browser) bodyHeight() int {
+	// Title, separator, separator, status and help lines
+	if h := b.height - 5; h > 1 {
+		return h
+	}
+	return 1
+}
+
+// Lines renders the screen as plain text lines, with styles as escape sequences.
+func (b *Browser) Lines() []string {
+	lines := make([]string, 0, b.height)
+	tabs := []string{" Environment ", " Profiles "}
+	tabs[b.view] = styleReverse + tabs[b.view] + styleReset
+	title := styleBold + "envirou" + styleReset + "  " + strings.Join(tabs, " ")
+	if b.view == viewEnvironment {
+		title += "  Filter: " + b.filter
+	}
+	lines = append(lines, title, strings.Repeat("─", b.width))
+
+	var body []string
+	if b.view == viewEnvironment {
+		body = b.environmentLines()
+	} else {
+		body = b.profileLines()
+	}
+	for len(body) < b.bodyHeight() {
+		body = append(body, "")
+	}
+	lines = append(lines, body...)
+	lines = append(lines, strings.Repeat("─", b.width), b.status())
+	if b.view == viewEnvironment {
+		lines = append(lines, fit("Type to filter  ↑↓ move  Enter reveal  ^T hidden groups  Tab profiles  Esc done  ^C cancel", b.width))
+	} else {
+		lines = append(lines, fit("↑↓ move  Space toggle profile  Tab environment  q done  ^C cancel", b.width))
+	}
+	return lines
+}
+
+func (b *Browser) environmentLines() []string {
+	height := b.bodyHeight()
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	} else if b.cursor >= b.offset+height {
+		b.offset = b.cursor - height + 1
+	}
+	if b.offset > 0 && b.offset >= len(b.rows) {
+		b.offset = 0
+	}
+	if len(b.rows) == 0 {
+		return []string{"No matching variables"}
+	}
+	lines := make([]string, 0, height)
+	for i := b.offset; i < len(b.rows) && len(lines) < height; i++ {
+		r := b.rows[i]
+		if r.header {
+			lines = append(lines, styleBold+fit("# "+r.text, b.width)+styleReset)
+			continue
+		}
+		marker := "  "
+		before, existed := b.opts.Env.Get(r.text)
+		if value, _ := b.env.Get(r.text); !existed {
+			marker = "+ "
+		} else if value != before {
+			marker = "~ "
+		}
+		line := fit(marker+r.text+"="+b.displayValue(r.text), b.width)
+		if i == b.cursor {
+			line = styleReverse + line + strings.Repeat(" ", b.width-utf8.RuneCountInString(line)) + styleReset
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (b *Browser) profileLines() []string {
+	if len(b.profileNames) == 0 {
+		return []string{"No profiles configured"}
+	}
+	preview := b.previewLines(b.profileNames[b.profileCursor])
+	height := b.bodyHeight()
+	// The list gets at least half of the screen, the preview the rest
+	listHeight := len(b.profileNames)
+	if listHeight > height-len(preview) {
+		listHeight = height - len(preview)
+		if half := (height + 1) / 2; listHeight < half {
+			listHeight = half
+		}
+		if listHeight > len(b.profileNames) {
+			listHeight = len(b.profileNames)
+		}
+	}
+	if b.profileCursor < b.profileOffset {
+		b.profileOffset = b.profileCursor
+	} else if b.profileCursor >= b.profileOffset+listHeight {
+		b.profileOffset = b.profileCursor - listHeight + 1
+	}
+	if b.profileOffset > len(b.profileNames)-listHeight {
+		b.profileOffset = len(b.profileNames) - listHeight
+	}
+
+	lines := make([]string, 0, height)
+	for i := b.profileOffset; i < b.profileOffset+listHeight; i++ {
+		name := b.profileNames[i]
+		profile := b.opts.Profiles[name]
+		check := "[ ] "
+		if b.env.IsMerged(&profile) {
+			check = "[x] "
+		}
+		line := fit(check+name, b.width)
+		if i == b.profileCursor {
+			line = styleReverse + line + strings.Repeat(" ", b.width-utf8.RuneCountInString(line)) + styleReset
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, preview...)
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lines
+}
+
+// previewLines shows what toggling the profile would change.
+func (b *Browser) previewLines(name string) []string {
+	profile := b.opts.Profiles[name]
+	action := "Activating"
+	if b.env.IsMerged(&profile) {
+		action = "Deactivating"
+	}
+	preview, _ := b.toggled(name)
+	changed, removed := b.env.Diff(preview)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	lines := []string{"", styleBold + fmt.Sprintf("%s %s changes:", action, name) + styleReset}
+	if len(changed)+len(removed) == 0 {
+		lines = append(lines, "  nothing")
+	}
+	for _, env := range changed {
+		value, _ := preview.Get(env)
+		value, _ = b.opts.Out.MaskValue(env, value)
+		lines = append(lines, fit("  "+env+"="+value, b.width))
+	}
+	for _, env := range removed {
+		lines = append(lines, fit("  unset "+env, b.width))
+	}
+	return lines
+}
+
+func (b *Browser) status() string {
+	changed, removed := b.opts.Env.Diff(b.env)
+	count := len(changed) + len(removed)
+	switch count {
+	case 0:
+		return "No changes"
+	case 1:
+		return "1 variable will change on exit"
+	}
+	return fmt.Sprintf("%d variables will change on exit", count)
+}
+
+// frame returns the escape sequences drawing the screen.
+func (b *Browser) frame() string {
+	var s strings.Builder
+	s.WriteString("\x1b[H")
+	for i, line := range b.Lines() {
+		if i > 0 {
+			s.WriteString("\r\n")
+		}
+		s.WriteString(line + "\x1b[K")
+	}
+	s.WriteString("\x1b[J")
+	return s.String()
+}
+
+// fit truncates s to width characters.
+func fit(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
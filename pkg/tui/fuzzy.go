@@ -0,0 +1,61 @@
+package tui
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// fuzzyScore returns how well pattern matches s as a case-insensitive subsequence.
+// Consecutive characters and matches at the start of a word score higher.
+func fuzzyScore(pattern, s string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+	p := []rune(strings.ToLower(pattern))
+	runes := []rune(s)
+	score := 0
+	previous := -2
+	j := 0
+	for i := 0; i < len(runes) && j < len(p); i++ {
+		if unicode.ToLower(runes[i]) != p[j] {
+			continue
+		}
+		score++
+		if i == previous+1 {
+			score += 3
+		}
+		if i == 0 || !unicode.IsLetter(runes[i-1]) && !unicode.IsDigit(runes[i-1]) {
+			score += 2
+		}
+		previous = i
+		j++
+	}
+	if j < len(p) {
+		return 0, false
+	}
+	// Prefer shorter names for equal matches
+	return score*100 - len(runes), true
+}
+
+// fuzzyFilter returns the items matching pattern, best matches first.
+func fuzzyFilter(pattern string, items []string) []string {
+	type scored struct {
+		item  string
+		score int
+	}
+	matches := make([]scored, 0, len(items))
+	for _, item := range items {
+		if score, ok := fuzzyScore(pattern, item); ok {
+			matches = append(matches, scored{item, score})
+		}
+	}
+	if pattern != "" {
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	}
+	result := make([]string, len(matches))
+	for i, m := range matches {
+		result[i] = m.item
+	}
+	return result
+}
@@ -0,0 +1,110 @@
+package tui
+
+import "unicode/utf8"
+
+// Key codes returned by parseKeys, KeyRune keys carry the typed character.
+const (
+	KeyRune = iota
+	KeyUp
+	KeyDown
+	KeyPageUp
+	KeyPageDown
+	KeyHome
+	KeyEnd
+	KeyEnter
+	KeyTab
+	KeyBackspace
+	KeyEscape
+	KeyCtrlC
+	KeyCtrlR
+	KeyCtrlT
+	KeyCtrlU
+	KeyUnknown
+)
+
+// Key is a single key press.
+type Key struct {
+	Code int
+	Rune rune
+}
+
+var controlKeys = map[byte]int{
+	0x03: KeyCtrlC,
+	0x08: KeyBackspace,
+	0x09: KeyTab,
+	0x0a: KeyEnter,
+	0x0d: KeyEnter,
+	0x0e: KeyDown, // Ctrl-N
+	0x10: KeyUp,   // Ctrl-P
+	0x12: KeyCtrlR,
+	0x14: KeyCtrlT,
+	0x15: KeyCtrlU,
+	0x7f: KeyBackspace,
+}
+
+var escapeKeys = map[string]int{
+	"[A": KeyUp, "OA": KeyUp,
+	"[B": KeyDown, "OB": KeyDown,
+	"[5~": KeyPageUp,
+	"[6~": KeyPageDown,
+	"[H":  KeyHome, "OH": KeyHome, "[1~": KeyHome,
+	"[F": KeyEnd, "OF": KeyEnd, "[4~": KeyEnd,
+}
+
+// parseKeys splits the bytes of one terminal read into key presses. An escape
+// byte on its own (not followed by a sequence in the same read) is the Esc key.
+func parseKeys(b []byte) []Key {
+	keys := make([]Key, 0, len(b))
+	for len(b) > 0 {
+		c := b[0]
+		switch {
+		case c == 0x1b && len(b) == 1:
+			keys = append(keys, Key{Code: KeyEscape})
+			b = b[1:]
+		case c == 0x1b:
+			n := escapeSequenceLength(b)
+			code, found := escapeKeys[string(b[1:n])]
+			if !found {
+				code = KeyUnknown
+			}
+			keys = append(keys, Key{Code: code})
+			b = b[n:]
+		case c < 0x20 || c == 0x7f:
+			code, found := controlKeys[c]
+			if !found {
+				code = KeyUnknown
+			}
+			keys = append(keys, Key{Code: code})
+			b = b[1:]
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, Key{Code: KeyRune, Rune: r})
+			b = b[size:]
+		}
+	}
+	return keys
+}
+
+// escapeSequenceLength returns the length of the escape sequence at the start of b.
+func escapeSequenceLength(b []byte) int {
+	if len(b) < 2 {
+		return len(b)
+	}
+	switch b[1] {
+	case 'O':
+		if len(b) < 3 {
+			return len(b)
+		}
+		return 3
+	case '[':
+		// CSI: parameters followed by a final byte in 0x40-0x7e
+		for i := 2; i < len(b); i++ {
+			if b[i] >= 0x40 && b[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return len(b)
+	}
+	// Alt+key: treat as escape followed by the key
+	return 1
+}
@@ -0,0 +1,225 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultPickerRows = 8
+	pickerPreviewRows = 4
+)
+
+// PickerOptions configure the inline picker.
+type PickerOptions struct {
+	Prompt  string
+	Items   []string
+	Query   string                           // Initial query
+	Active  map[string]bool                  // Items shown as active
+	Preview func(selected []string) []string // Lines describing the effect of choosing the items
+	Rows    int                              // Number of items shown at once
+}
+
+// Picker is the state of an inline fuzzy multi-select picker.
+type Picker struct {
+	opts     PickerOptions
+	query    string
+	matches  []string
+	selected map[string]bool
+	order    []string // Selected items in the order they were selected
+	cursor   int
+	offset   int
+	width    int
+
+	done     bool
+	accepted bool
+}
+
+// NewPicker creates a picker over opts.Items.
+func NewPicker(opts PickerOptions) *Picker {
+	if opts.Rows <= 0 {
+		opts.Rows = defaultPickerRows
+	}
+	p := &Picker{
+		opts:     opts,
+		query:    opts.Query,
+		selected: make(map[string]bool),
+		width:    defaultWidth,
+	}
+	p.matches = fuzzyFilter(p.query, opts.Items)
+	return p
+}
+
+// RunPicker shows the picker below the cursor on term. It returns the chosen items, in the
+// order they were selected, and false if the picker was cancelled.
+func RunPicker(term Terminal, opts PickerOptions) ([]string, bool, error) {
+	p := NewPicker(opts)
+	drawn := 0
+	clear := func() string {
+		if drawn > 1 {
+			return fmt.Sprintf("\r\x1b[%dA\x1b[J", drawn-1)
+		}
+		return "\r\x1b[J"
+	}
+	defer func() { io.WriteString(term, clear()) }()
+
+	buf := make([]byte, 256)
+	for !p.done {
+		if width, _ := term.Size(); width > 0 {
+			p.width = width
+		}
+		lines := p.Lines()
+		frame := clear() + strings.Join(lines, "\r\n")
+		drawn = len(lines)
+		if _, err := io.WriteString(term, frame); err != nil {
+			return nil, false, err
+		}
+		n, err := term.Read(buf)
+		for _, key := range parseKeys(buf[:n]) {
+			p.HandleKey(key)
+			if p.done {
+				break
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil, false, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+	}
+	if !p.accepted {
+		return nil, false, nil
+	}
+	return p.Chosen(), true, nil
+}
+
+// Chosen returns the selected items, or the item under the cursor if none are selected.
+func (p *Picker) Chosen() []string {
+	if len(p.order) > 0 {
+		return append([]string{}, p.order...)
+	}
+	if p.cursor < len(p.matches) {
+		return []string{p.matches[p.cursor]}
+	}
+	return nil
+}
+
+// HandleKey updates the picker for a key press.
+func (p *Picker) HandleKey(key Key) {
+	switch key.Code {
+	case KeyRune:
+		p.setQuery(p.query + string(key.Rune))
+	case KeyBackspace:
+		if p.query != "" {
+			_, size := utf8.DecodeLastRuneInString(p.query)
+			p.setQuery(p.query[:len(p.query)-size])
+		}
+	case KeyCtrlU:
+		p.setQuery("")
+	case KeyUp:
+		p.move(-1)
+	case KeyDown:
+		p.move(1)
+	case KeyPageUp:
+		p.move(-p.opts.Rows)
+	case KeyPageDown:
+		p.move(p.opts.Rows)
+	case KeyTab:
+		if p.cursor < len(p.matches) {
+			p.toggle(p.matches[p.cursor])
+			p.move(1)
+		}
+	case KeyEnter:
+		p.done = true
+		p.accepted = len(p.Chosen()) > 0
+	case KeyEscape, KeyCtrlC:
+		p.done, p.accepted = true, false
+	}
+}
+
+func (p *Picker) setQuery(query string) {
+	p.query = query
+	p.matches = fuzzyFilter(query, p.opts.Items)
+	p.cursor, p.offset = 0, 0
+}
+
+func (p *Picker) move(delta int) {
+	p.cursor += delta
+	if p.cursor >= len(p.matches) {
+		p.cursor = len(p.matches) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+func (p *Picker) toggle(item string) {
+	if p.selected[item] {
+		delete(p.selected, item)
+		for i, selected := range p.order {
+			if selected == item {
+				p.order = append(p.order[:i], p.order[i+1:]...)
+				break
+			}
+		}
+	} else {
+		p.selected[item] = true
+		p.order = append(p.order, item)
+	}
+}
+
+// Lines renders the picker, it always has the same number of lines.
+func (p *Picker) Lines() []string {
+	lines := make([]string, 0, p.opts.Rows+pickerPreviewRows+2)
+	count := fmt.Sprintf("%d/%d", len(p.matches), len(p.opts.Items))
+	if len(p.order) > 0 {
+		count += fmt.Sprintf(" (%d selected)", len(p.order))
+	}
+	lines = append(lines, fit(fmt.Sprintf("%s%s  %s", p.opts.Prompt, p.query, count), p.width))
+
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	} else if p.cursor >= p.offset+p.opts.Rows {
+		p.offset = p.cursor - p.opts.Rows + 1
+	}
+	for i := p.offset; i < p.offset+p.opts.Rows; i++ {
+		if i >= len(p.matches) {
+			lines = append(lines, "")
+			continue
+		}
+		item := p.matches[i]
+		check := "[ ] "
+		if p.selected[item] {
+			check = "[x] "
+		}
+		line := "  " + check + item
+		if p.opts.Active[item] {
+			line += " (active)"
+		}
+		line = fit(line, p.width)
+		if i == p.cursor {
+			line = styleReverse + ">" + line[1:] + styleReset
+		}
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, styleBold+fit("Tab select  Enter set  Esc cancel", p.width)+styleReset)
+	var preview []string
+	if chosen := p.Chosen(); len(chosen) > 0 && p.opts.Preview != nil {
+		preview = p.opts.Preview(chosen)
+	}
+	for i := 0; i < pickerPreviewRows; i++ {
+		switch {
+		case i == pickerPreviewRows-1 && len(preview) > pickerPreviewRows:
+			lines = append(lines, fmt.Sprintf("  … %d more", len(preview)-i))
+		case i < len(preview):
+			lines = append(lines, fit("  "+preview[i], p.width))
+		default:
+			lines = append(lines, "")
+		}
+	}
+	return lines
+}
@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd || windows)
+
+package tui
+
+import (
+	"errors"
+	"os"
+)
+
+func makeRaw(_, _ *os.File) (func() error, error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+func terminalSize(_ *os.File) (int, int) {
+	return 0, 0
+}
@@ -0,0 +1,41 @@
+//go:build windows
+
+package tui
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+func makeRaw(in, out *os.File) (func() error, error) {
+	inHandle := windows.Handle(in.Fd())
+	outHandle := windows.Handle(out.Fd())
+	var inMode, outMode uint32
+	if err := windows.GetConsoleMode(inHandle, &inMode); err != nil {
+		return nil, err
+	}
+	if err := windows.GetConsoleMode(outHandle, &outMode); err != nil {
+		return nil, err
+	}
+	raw := inMode &^ (windows.ENABLE_ECHO_INPUT | windows.ENABLE_LINE_INPUT | windows.ENABLE_PROCESSED_INPUT)
+	if err := windows.SetConsoleMode(inHandle, raw|windows.ENABLE_VIRTUAL_TERMINAL_INPUT); err != nil {
+		return nil, err
+	}
+	if err := windows.SetConsoleMode(outHandle, outMode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+		_ = windows.SetConsoleMode(inHandle, inMode)
+		return nil, err
+	}
+	return func() error {
+		_ = windows.SetConsoleMode(outHandle, outMode)
+		return windows.SetConsoleMode(inHandle, inMode)
+	}, nil
+}
+
+func terminalSize(f *os.File) (int, int) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(f.Fd()), &info); err != nil {
+		return 0, 0
+	}
+	return int(info.Window.Right - info.Window.Left + 1), int(info.Window.Bottom - info.Window.Top + 1)
+}
@@ -0,0 +1,53 @@
+package tui
+
+import (
+	"io"
+	"os"
+)
+
+// Terminal is what the browser reads keys from and draws on.
+type Terminal interface {
+	io.ReadWriter
+	// Size returns the number of columns and rows, or zeros if unknown.
+	Size() (width, height int)
+}
+
+// TTY is a terminal in raw mode.
+type TTY struct {
+	in      *os.File
+	out     *os.File
+	restore func() error
+}
+
+// OpenTTY puts the terminal connected to in into raw mode, drawing on out.
+// Call Close to restore the previous mode.
+func OpenTTY(in, out *os.File) (*TTY, error) {
+	restore, err := makeRaw(in, out)
+	if err != nil {
+		return nil, err
+	}
+	return &TTY{in: in, out: out, restore: restore}, nil
+}
+
+func (t *TTY) Read(p []byte) (int, error) {
+	return t.in.Read(p)
+}
+
+func (t *TTY) Write(p []byte) (int, error) {
+	return t.out.Write(p)
+}
+
+// Size returns the size of the output terminal.
+func (t *TTY) Size() (int, int) {
+	return terminalSize(t.out)
+}
+
+// Close restores the terminal mode, it is safe to call more than once.
+func (t *TTY) Close() error {
+	if t.restore == nil {
+		return nil
+	}
+	err := t.restore()
+	t.restore = nil
+	return err
+}
@@ -0,0 +1,277 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sverrirab/envirou/pkg/data"
+	"github.com/sverrirab/envirou/pkg/output"
+)
+
+// fakeTerminal returns one scripted read per call and records everything drawn.
+type fakeTerminal struct {
+	reads  []string
+	screen bytes.Buffer
+}
+
+func (f *fakeTerminal) Read(p []byte) (int, error) {
+	if len(f.reads) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, f.reads[0])
+	f.reads = f.reads[1:]
+	return n, nil
+}
+
+func (f *fakeTerminal) Write(p []byte) (int, error) {
+	return f.screen.Write(p)
+}
+
+func (f *fakeTerminal) Size() (int, int) {
+	return 60, 16
+}
+
+var escapes = regexp.MustCompile("\x1b\\[[0-9;?]*[A-Za-z]")
+
+// lastFrame returns the text of the last screen drawn, without styles.
+func (f *fakeTerminal) lastFrame() string {
+	frames := strings.Split(f.screen.String(), "\x1b[H")
+	return escapes.ReplaceAllString(frames[len(frames)-1], "")
+}
+
+func newTestOptions() Options {
+	env := data.NewProfile(false)
+	env.MergeStrings([]string{"AWS_PROFILE=dev", "AWS_TOKEN=secret-value", "HOME=/home/me", "EDITOR=vim", "OTHER=1"})
+
+	groups := data.NewGroups()
+	groups.ParseAndAdd("aws", "AWS_*", false)
+	groups.ParseAndAdd(".system", "HOME, EDITOR", false)
+
+	prod := data.NewProfile(false)
+	prod.Set("AWS_PROFILE", "prod")
+	dev := data.NewProfile(false)
+	dev.Set("AWS_PROFILE", "dev")
+	tools := data.NewProfile(false)
+	tools.Set("TOOLS", "1")
+
+	none := data.CompilePatterns(data.ParsePatterns("", false), false)
+	passwords := data.CompilePatterns(data.ParsePatterns("AWS_TOKEN", false), false)
+	return Options{
+		Env:      env,
+		Groups:   groups,
+		Profiles: data.Profiles{"prod": *prod, "dev": *dev, "tools": *tools},
+		Out:      output.NewOutput("", none, passwords, false, "", "", "", "", ""),
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := parseKeys([]byte("a\x1b[A\x1b[6~\r\x7f\x03é\x1b"))
+	expected := []Key{{Code: KeyRune, Rune: 'a'}, {Code: KeyUp}, {Code: KeyPageDown}, {Code: KeyEnter}, {Code: KeyBackspace}, {Code: KeyCtrlC}, {Code: KeyRune, Rune: 'é'}, {Code: KeyEscape}}
+	if len(keys) != len(expected) {
+		t.Fatalf("Unexpected keys: %v", keys)
+	}
+	for i := range keys {
+		if keys[i] != expected[i] {
+			t.Errorf("Key %d: %v != %v", i, keys[i], expected[i])
+		}
+	}
+}
+
+func TestBrowserListing(t *testing.T) {
+	term := &fakeTerminal{reads: []string{"\x03"}}
+	_, apply, err := Run(term, newTestOptions())
+	if err != nil || apply {
+		t.Fatalf("Ctrl-C should cancel: %v %v", apply, err)
+	}
+	screen := term.lastFrame()
+	for _, expected := range []string{"# aws", "AWS_PROFILE=dev", "AWS_TOKEN=" + output.MaskedValue, "# (no group)", "OTHER=1", "No changes"} {
+		if !strings.Contains(screen, expected) {
+			t.Errorf("Expected %q on screen:\n%s", expected, screen)
+		}
+	}
+	if strings.Contains(screen, "HOME") {
+		t.Errorf("Hidden groups should not be shown:\n%s", screen)
+	}
+}
+
+func TestBrowserFilterRevealAndHidden(t *testing.T) {
+	b := NewBrowser(newTestOptions())
+	for _, key := range parseKeys([]byte("tok\r")) {
+		b.HandleKey(key)
+	}
+	screen := strings.Join(b.Lines(), "\n")
+	if !strings.Contains(screen, "AWS_TOKEN=secret-value") || strings.Contains(screen, "AWS_PROFILE") {
+		t.Errorf("Expected only the revealed token:\n%s", screen)
+	}
+
+	// Esc clears the filter, Ctrl-T shows hidden groups
+	b.HandleKey(Key{Code: KeyEscape})
+	b.HandleKey(Key{Code: KeyCtrlT})
+	screen = escapes.ReplaceAllString(strings.Join(b.Lines(), "\n"), "")
+	if !strings.Contains(screen, "# .system") || !strings.Contains(screen, "HOME=/home/me") {
+		t.Errorf("Expected hidden group:\n%s", screen)
+	}
+	if b.done {
+		t.Error("Esc with a filter should not exit")
+	}
+}
+
+func TestBrowserToggleProfiles(t *testing.T) {
+	// Profiles are sorted: dev, prod, tools. Activating prod replaces the value set by dev.
+	term := &fakeTerminal{reads: []string{"\t", "\x1b[B", " ", "\x1b[B", "\r", "q"}}
+	env, apply, err := Run(term, newTestOptions())
+	if err != nil || !apply {
+		t.Fatalf("Expected changes to be applied: %v %v", apply, err)
+	}
+	if value, _ := env.Get("AWS_PROFILE"); value != "prod" {
+		t.Errorf("Expected prod profile, got %s", value)
+	}
+	if value, _ := env.Get("TOOLS"); value != "1" {
+		t.Errorf("Expected tools profile, got %s", value)
+	}
+	screen := term.lastFrame()
+	if !strings.Contains(screen, "[x] prod") || !strings.Contains(screen, "[ ] dev") || !strings.Contains(screen, "[x] tools") {
+		t.Errorf("Unexpected profile states:\n%s", screen)
+	}
+	if !strings.Contains(screen, "2 variables will change on exit") {
+		t.Errorf("Expected change count:\n%s", screen)
+	}
+}
+
+func TestBrowserPreviewAndDeactivate(t *testing.T) {
+	b := NewBrowser(newTestOptions())
+	b.HandleKey(Key{Code: KeyTab})
+	screen := strings.Join(b.Lines(), "\n")
+	// dev is active, so the preview shows deactivating it
+	if !strings.Contains(screen, "Deactivating dev changes:") || !strings.Contains(screen, "unset AWS_PROFILE") {
+		t.Errorf("Expected deactivation preview:\n%s", screen)
+	}
+	b.HandleKey(Key{Code: KeyRune, Rune: ' '})
+	if _, found := b.Env().Get("AWS_PROFILE"); found {
+		t.Error("Deactivating dev should unset AWS_PROFILE")
+	}
+	b.HandleKey(Key{Code: KeyTab})
+	screen = strings.Join(b.Lines(), "\n")
+	if strings.Contains(screen, "AWS_PROFILE") {
+		t.Errorf("AWS_PROFILE should no longer be listed:\n%s", screen)
+	}
+}
+
+func TestBrowserToggleRestoresValues(t *testing.T) {
+	b := NewBrowser(newTestOptions())
+	b.HandleKey(Key{Code: KeyTab})
+	b.HandleKey(Key{Code: KeyDown})
+	// Activating and deactivating prod gives back the value set by dev
+	b.HandleKey(Key{Code: KeyRune, Rune: ' '})
+	if value, _ := b.Env().Get("AWS_PROFILE"); value != "prod" {
+		t.Errorf("Expected prod profile, got %s", value)
+	}
+	screen := strings.Join(b.Lines(), "\n")
+	if !strings.Contains(screen, "Deactivating prod changes:") || !strings.Contains(screen, "AWS_PROFILE=dev") {
+		t.Errorf("Expected the preview to restore dev:\n%s", screen)
+	}
+	b.HandleKey(Key{Code: KeyRune, Rune: ' '})
+	if value, found := b.Env().Get("AWS_PROFILE"); value != "dev" || !found {
+		t.Errorf("Expected AWS_PROFILE=dev to be restored, got %q", value)
+	}
+	if changed, removed := b.opts.Env.Diff(b.Env()); len(changed)+len(removed) != 0 {
+		t.Errorf("Expected no changes, got %v %v", changed, removed)
+	}
+}
+
+func TestBrowserProfileScrolling(t *testing.T) {
+	opts := newTestOptions()
+	for i := 0; i < 30; i++ {
+		profile := data.NewProfile(false)
+		profile.Set("NUMBER", fmt.Sprint(i))
+		opts.Profiles[fmt.Sprintf("p%02d", i)] = *profile
+	}
+	b := NewBrowser(opts)
+	b.SetSize(60, 16)
+	b.HandleKey(Key{Code: KeyTab})
+	b.HandleKey(Key{Code: KeyEnd})
+	screen := escapes.ReplaceAllString(strings.Join(b.Lines(), "\n"), "")
+	if len(b.Lines()) != 16 {
+		t.Errorf("Expected the screen to be filled exactly, got %d lines", len(b.Lines()))
+	}
+	if !strings.Contains(screen, "[ ] tools") || !strings.Contains(screen, "Activating tools changes:") || !strings.Contains(screen, "TOOLS=1") {
+		t.Errorf("Expected the last profile and its preview:\n%s", screen)
+	}
+	if strings.Contains(screen, "[x] dev") {
+		t.Errorf("Expected the first profiles to be scrolled away:\n%s", screen)
+	}
+	b.HandleKey(Key{Code: KeyHome})
+	screen = escapes.ReplaceAllString(strings.Join(b.Lines(), "\n"), "")
+	if !strings.Contains(screen, "[x] dev") || !strings.Contains(screen, "Deactivating dev changes:") {
+		t.Errorf("Expected the first profile and its preview:\n%s", screen)
+	}
+}
+
+func TestFuzzyFilter(t *testing.T) {
+	items := []string{"aws-prod", "aws-dev", "gcp-prod", "prod", "local"}
+	matches := fuzzyFilter("prod", items)
+	if len(matches) != 3 || matches[0] != "prod" {
+		t.Errorf("Unexpected matches: %v", matches)
+	}
+	matches = fuzzyFilter("wsdv", items)
+	if len(matches) != 1 || matches[0] != "aws-dev" {
+		t.Errorf("Unexpected subsequence matches: %v", matches)
+	}
+	if matches = fuzzyFilter("", items); len(matches) != len(items) || matches[0] != "aws-prod" {
+		t.Errorf("Empty pattern should keep the order: %v", matches)
+	}
+}
+
+func TestPickerMultiSelect(t *testing.T) {
+	var previewed []string
+	term := &fakeTerminal{reads: []string{"aws", "\t", "\t", "\r"}}
+	picked, ok, err := RunPicker(term, PickerOptions{
+		Prompt: "set> ",
+		Items:  []string{"aws-prod", "aws-dev", "gcp-prod"},
+		Active: map[string]bool{"aws-dev": true},
+		Preview: func(selected []string) []string {
+			previewed = selected
+			return []string{"AWS_PROFILE=" + selected[0]}
+		},
+	})
+	if err != nil || !ok {
+		t.Fatalf("Expected picked profiles: %v %v", ok, err)
+	}
+	// The shorter aws-dev is the better match, so it is listed and selected first
+	if len(picked) != 2 || picked[0] != "aws-dev" || picked[1] != "aws-prod" {
+		t.Errorf("Unexpected picked profiles: %v", picked)
+	}
+	if len(previewed) != 2 {
+		t.Errorf("Expected preview of both selections, got %v", previewed)
+	}
+	screen := term.lastFrame()
+	for _, expected := range []string{"set> aws  2/3 (2 selected)", "[x] aws-dev (active)", "AWS_PROFILE=aws-dev"} {
+		if !strings.Contains(screen, expected) {
+			t.Errorf("Expected %q on screen:\n%s", expected, screen)
+		}
+	}
+}
+
+func TestPickerCursorAndCancel(t *testing.T) {
+	p := NewPicker(PickerOptions{Items: []string{"dev", "prod"}, Query: "p"})
+	if chosen := p.Chosen(); len(chosen) != 1 || chosen[0] != "prod" {
+		t.Errorf("Expected the cursor item, got %v", chosen)
+	}
+	p.HandleKey(Key{Code: KeyBackspace})
+	p.HandleKey(Key{Code: KeyDown})
+	if chosen := p.Chosen(); len(chosen) != 1 || chosen[0] != "prod" {
+		t.Errorf("Expected prod after moving down, got %v", chosen)
+	}
+
+	picked, ok, err := RunPicker(&fakeTerminal{reads: []string{"\x1b"}}, PickerOptions{Items: []string{"dev"}})
+	if ok || err != nil || picked != nil {
+		t.Errorf("Esc should cancel: %v %v %v", picked, ok, err)
+	}
+	if _, ok, _ = RunPicker(&fakeTerminal{reads: []string{"zzz\r"}}, PickerOptions{Items: []string{"dev"}}); ok {
+		t.Error("Enter without matches should not accept")
+	}
+}
@@ -1,6 +1,14 @@
 function ev {
-    $output = & envirou --output-powershell $args
-    if ($output.Length -ne 0) {
+    # envirou writes the commands to a file and keeps stdout for its own output
+    $commands = New-TemporaryFile
+    $Env:ENVIROU_CMD_FILE = $commands.FullName
+    & envirou --shell powershell $args
+    $status = $LASTEXITCODE
+    Remove-Item Env:ENVIROU_CMD_FILE
+    $output = Get-Content -Raw -Encoding UTF8 $commands.FullName
+    Remove-Item $commands.FullName
+    if ($output) {
         Invoke-Expression $output
     }
+    $global:LASTEXITCODE = $status
 }